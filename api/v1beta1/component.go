@@ -15,9 +15,14 @@
 package v1beta1
 
 import (
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 type NetworkType string
@@ -46,6 +51,29 @@ const (
 	IOBound  string = "io_bound"
 )
 
+type ExposeType string
+
+const (
+	ExposeNodePort     ExposeType = "NodePort"
+	ExposeLoadBalancer ExposeType = "LoadBalancer"
+	ExposeIngressNginx ExposeType = "IngressNginx"
+)
+
+type RoutingMode string
+
+const (
+	RoutingModeIngress RoutingMode = "ingress"
+	RoutingModeGateway RoutingMode = "gateway"
+)
+
+type ServiceAddressFormat string
+
+const (
+	ServiceAddressFQDN       ServiceAddressFormat = "fqdn"
+	ServiceAddressNamespaced ServiceAddressFormat = "namespaced"
+	ServiceAddressShort      ServiceAddressFormat = "short"
+)
+
 type ConfigurationType string
 
 const (
@@ -53,12 +81,44 @@ const (
 	ConfigurationConfigMap = "ConfigMap"
 )
 
+// RetainPolicy decides whether the resource which outlives the Erda is deleted with it
+type RetainPolicy string
+
+const (
+	RetainPolicyRetain RetainPolicy = "Retain"
+	RetainPolicyDelete RetainPolicy = "Delete"
+)
+
 const (
 	AnnotationSSLEnabled           = "erda.erda.cloud/ssl-enabled"
 	AnnotationIngressAnnotation    = "erda.erda.cloud/ingress-annotations"
+	AnnotationIngressClass         = "erda.erda.cloud/ingress-class"
+	AnnotationRoutingMode          = "erda.erda.cloud/routing-mode"
+	AnnotationGateway              = "erda.erda.cloud/gateway"
+	AnnotationServiceAddressFormat = "erda.erda.cloud/service-address-format"
+	AnnotationNetworkPolicy        = "erda.erda.cloud/network-policy"
+	AnnotationSkipPreDeleteJobs    = "erda.erda.cloud/skip-pre-delete-jobs"
+	AnnotationHibernate            = "erda.erda.cloud/hibernate"
+	AnnotationCanaryPromote        = "erda.erda.cloud/canary-promote"
+	AnnotationCanaryAbort          = "erda.erda.cloud/canary-abort"
+	AnnotationCanaryAborted        = "erda.erda.cloud/canary-aborted"
+	AnnotationCanaryStep           = "erda.erda.cloud/canary-step"
+	AnnotationCanaryStepTime       = "erda.erda.cloud/canary-step-time"
+	AnnotationBlueGreenSwitchTime  = "erda.erda.cloud/blue-green-switch-time"
+	AnnotationTemplateHash         = "erda.erda.cloud/template-hash"
+	AnnotationRolloutTime          = "erda.erda.cloud/rollout-time"
+	AnnotationLastKnownGood        = "erda.erda.cloud/last-known-good"
+	AnnotationRollback             = "erda.erda.cloud/rollback"
+	AnnotationRollbackReason       = "erda.erda.cloud/rollback-reason"
+	AnnotationRollbackTo           = "erda.erda.cloud/rollback-to"
+	AnnotationRevisionOutcome      = "erda.erda.cloud/revision-outcome"
+	AnnotationRevisionAppliedTime  = "erda.erda.cloud/revision-applied-time"
 	AnnotationComponentSA          = "erda.erda.cloud/component-service-account"
 	AnnotationComponentPrivileged  = "erda.erda.cloud/component-security-context-privileged"
 	AnnotationComponentAnnotations = "erda.erda.cloud/component-annotations"
+	// AnnotationDesiredHash records the hash of the desired state of the rendered object,
+	// the object is updated when the hash changes
+	AnnotationDesiredHash = "erda.erda.cloud/desired-hash"
 )
 
 type Component struct {
@@ -81,11 +141,90 @@ type ComponentSpec struct {
 	Network        *Network                    `yaml:"network,omitempty" json:"network,omitempty"`
 	HealthCheck    *HealthCheck                `yaml:"healthCheck,omitempty" json:"healthCheck,omitempty"`
 	Configurations []Configuration             `yaml:"configurations,omitempty" json:"configurations,omitempty"`
+	// Autoscaling creates a HorizontalPodAutoscaler for the Stateless and Stateful component,
+	// the replicas is managed by the autoscaler after the workload is created
+	Autoscaling *Autoscaling `yaml:"autoscaling,omitempty" json:"autoscaling,omitempty"`
+	// DisruptionBudget configures the PodDisruptionBudget of the Stateless and Stateful component
+	// which has more than one replica, at most one pod is unavailable by default
+	DisruptionBudget *DisruptionBudget `yaml:"disruptionBudget,omitempty" json:"disruptionBudget,omitempty"`
+	// Rollout configures the update strategy of the workload, the Kubernetes defaults are used if not set
+	Rollout *Rollout `yaml:"rollout,omitempty" json:"rollout,omitempty"`
+	// Canary releases a canary Deployment next to the one of the Stateless component,
+	// the ingress traffic is shifted to it by the weight steps
+	Canary *Canary `yaml:"canary,omitempty" json:"canary,omitempty"`
 
-	// TODO: impl
+	// DependsOn declares the components which the component depends on, when the network policies
+	// are enabled only the dependents are allowed to access the ports of the component
 	DependsOn []string `yaml:"dependsOn,omitempty" json:"dependsOn,omitempty"`
 }
 
+type Autoscaling struct {
+	// MinReplicas is 1 by default
+	//+kubebuilder:validation:Minimum=1
+	MinReplicas *int32 `yaml:"minReplicas,omitempty" json:"minReplicas,omitempty"`
+	//+kubebuilder:validation:Minimum=1
+	MaxReplicas int32 `yaml:"maxReplicas" json:"maxReplicas"`
+	// TargetCPUUtilization and TargetMemoryUtilization are the average utilization percentages
+	// of the resource requests, 80% of CPU is used if no metric is specified
+	TargetCPUUtilization    *int32 `yaml:"targetCPUUtilization,omitempty" json:"targetCPUUtilization,omitempty"`
+	TargetMemoryUtilization *int32 `yaml:"targetMemoryUtilization,omitempty" json:"targetMemoryUtilization,omitempty"`
+	// Metrics are the custom metric targets which are appended to the utilization targets
+	Metrics []autoscalingv2beta2.MetricSpec `yaml:"metrics,omitempty" json:"metrics,omitempty"`
+}
+
+// DisruptionBudget accepts one of MinAvailable and MaxUnavailable, MinAvailable takes precedence
+type DisruptionBudget struct {
+	MinAvailable   *intstr.IntOrString `yaml:"minAvailable,omitempty" json:"minAvailable,omitempty"`
+	MaxUnavailable *intstr.IntOrString `yaml:"maxUnavailable,omitempty" json:"maxUnavailable,omitempty"`
+}
+
+type Rollout struct {
+	// MaxSurge is the rolling update max surge of the Deployment, 25% is default
+	MaxSurge *intstr.IntOrString `yaml:"maxSurge,omitempty" json:"maxSurge,omitempty"`
+	// MaxUnavailable is the rolling update max unavailable of the Deployment and the DaemonSet,
+	// 25% is default for the Deployment and 1 is default for the DaemonSet
+	MaxUnavailable *intstr.IntOrString `yaml:"maxUnavailable,omitempty" json:"maxUnavailable,omitempty"`
+	// Partition is the ordinal of the StatefulSet from which the pods are updated
+	Partition *int32 `yaml:"partition,omitempty" json:"partition,omitempty"`
+	// PodManagementPolicy of the StatefulSet, it is immutable and only applied when the StatefulSet is created
+	//+kubebuilder:validation:Enum={OrderedReady,Parallel}
+	PodManagementPolicy appsv1.PodManagementPolicyType `yaml:"podManagementPolicy,omitempty" json:"podManagementPolicy,omitempty"`
+	// MinReadySeconds is applied to the Deployment and the DaemonSet
+	MinReadySeconds int32 `yaml:"minReadySeconds,omitempty" json:"minReadySeconds,omitempty"`
+	// ProgressDeadlineSeconds of the Deployment, it is also the deadline after which the rollouts of
+	// the StatefulSet and the DaemonSet are stalled, 600 is default
+	ProgressDeadlineSeconds *int32 `yaml:"progressDeadlineSeconds,omitempty" json:"progressDeadlineSeconds,omitempty"`
+	// RevisionHistoryLimit of the workload, 3 is default
+	RevisionHistoryLimit *int32 `yaml:"revisionHistoryLimit,omitempty" json:"revisionHistoryLimit,omitempty"`
+	// BlueGreen releases the new version of the Stateless component as the Deployment of the other color,
+	// the Service is switched to it after it is available, the component with Canary or Autoscaling is rejected
+	BlueGreen *BlueGreen `yaml:"blueGreen,omitempty" json:"blueGreen,omitempty"`
+	// AutoRollback rolls the workload back to the last known-good pod template when the rollout exceeds
+	// the progress deadline, the failed template is not applied again until the component is changed
+	AutoRollback bool `yaml:"autoRollback,omitempty" json:"autoRollback,omitempty"`
+}
+
+// BlueGreen keeps the Deployment of the previous color for the rollback, the rollback is switched
+// instantly by reverting the component in the window, it can not be combined with Autoscaling
+type BlueGreen struct {
+	// RollbackWindow is the duration the previous color is kept after the switch, 10m is default
+	RollbackWindow *metav1.Duration `yaml:"rollbackWindow,omitempty" json:"rollbackWindow,omitempty"`
+}
+
+// Canary is finished when the image of the component is updated to the canary image,
+// the canary resources are removed after the Deployment of the component is ready
+type Canary struct {
+	Image string `yaml:"image" json:"image"`
+	// Replicas of the canary Deployment, 1 is default
+	Replicas *int32 `yaml:"replicas,omitempty" json:"replicas,omitempty"`
+	// Steps are the ingress traffic weight percentages of the canary, all traffic is
+	// shifted to the canary after the last step
+	Steps []int32 `yaml:"steps,omitempty" json:"steps,omitempty"`
+	// Pause is the duration between the steps, the steps are only advanced by
+	// the promote annotation if it is not set
+	Pause *metav1.Duration `yaml:"pause,omitempty" json:"pause,omitempty"`
+}
+
 type HealthCheck struct {
 	Duration  int32      `yaml:"duration,omitempty" json:"duration,omitempty"`
 	HTTPCheck *HTTPCheck `yaml:"httpCheck,omitempty" json:"httpCheck,omitempty"`
@@ -107,6 +246,10 @@ type Configuration struct {
 	TargetPath string            `yaml:"targetPath" json:"targetPath"`
 	Data       map[string][]byte `yaml:"data,omitempty" json:"data,omitempty"`
 	StringData map[string]string `yaml:"stringData,omitempty" json:"stringData,omitempty"`
+	// RetainPolicy decides whether the configuration with data is kept when the Erda is deleted,
+	// it is retained by default
+	//+kubebuilder:validation:Enum={Retain,Delete}
+	RetainPolicy RetainPolicy `yaml:"retainPolicy,omitempty" json:"retainPolicy,omitempty"`
 }
 
 type Affinity struct {
@@ -136,6 +279,10 @@ type Volume struct {
 	TargetPath   string             `yaml:"targetPath,omitempty" json:"targetPath,omitempty"`
 	ReadOnly     bool               `yaml:"readOnly,omitempty" json:"readOnly,omitempty"`
 	Snapshot     *VolumeSnapshot    `yaml:"snapshot,omitempty" json:"snapshot,omitempty"`
+	// RetainPolicy decides whether the claim of the volume with storage class is kept when
+	// the Erda is deleted, it is retained by default
+	//+kubebuilder:validation:Enum={Retain,Delete}
+	RetainPolicy RetainPolicy `yaml:"retainPolicy,omitempty" json:"retainPolicy,omitempty"`
 }
 
 type VolumeSnapshot struct {
@@ -148,6 +295,10 @@ type Network struct {
 	//use the first port as domain port
 	ServiceDiscovery []ServiceDiscovery `yaml:"serviceDiscovery,omitempty" json:"serviceDiscovery,omitempty"`
 	Microservices    *Microservices     `yaml:"microservice,omitempty" json:"microservices,omitempty"`
+	// OrdinalServices creates a Service for every pod of the Stateful component,
+	// named as <name>-<ordinal>, for the clustered software which advertises per-member addresses,
+	// the StatefulSet is governed by the headless Service <name>-headless
+	OrdinalServices bool `yaml:"ordinalServices,omitempty" json:"ordinalServices,omitempty"`
 }
 
 type ServiceDiscovery struct {
@@ -155,6 +306,21 @@ type ServiceDiscovery struct {
 	Protocol string `yaml:"protocol" json:"protocol"`
 	Domain   string `yaml:"domain,omitempty" json:"domain,omitempty"`
 	Path     string `yaml:"path,omitempty" json:"path,omitempty"`
+	// PathType is the ingress path match type, ImplementationSpecific is default
+	//+kubebuilder:validation:Enum={Exact,Prefix,ImplementationSpecific}
+	PathType networkingv1.PathType `yaml:"pathType,omitempty" json:"pathType,omitempty"`
+	// Expose exposes the port outside the cluster besides the ingress
+	Expose *Expose `yaml:"expose,omitempty" json:"expose,omitempty"`
+}
+
+type Expose struct {
+	//+kubebuilder:validation:Enum={NodePort,LoadBalancer,IngressNginx}
+	Type ExposeType `yaml:"type" json:"type"`
+	// Port is the external port of LoadBalancer and IngressNginx, the service discovery port is default
+	Port int32 `yaml:"port,omitempty" json:"port,omitempty"`
+	// NodePort pins the node port of NodePort and LoadBalancer, it is allocated by Kubernetes if not set
+	NodePort     int32    `yaml:"nodePort,omitempty" json:"nodePort,omitempty"`
+	SourceRanges []string `yaml:"sourceRanges,omitempty" json:"sourceRanges,omitempty"`
 }
 
 type TrafficSecurity struct {
@@ -22,7 +22,7 @@ type Job struct {
 }
 
 type JobSpec struct {
-	//+kubebuilder:validation:Enum={PreJob}
+	//+kubebuilder:validation:Enum={PreJob,PreDelete}
 	Type      JobType                     `yaml:"type" json:"type"`
 	Retries   *int32                      `yaml:"retries,omitempty" json:"retries,omitempty"`
 	ImageInfo ImageInfo                   `yaml:"imageInfo" json:"imageInfo"`
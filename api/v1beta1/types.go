@@ -27,6 +27,10 @@ const (
 	StatusFailed    StatusType = "Failed"
 	StatusCompleted StatusType = "Completed"
 	StatusUnKnown   StatusType = "UnKnown"
+	// StatusUnReady means the workload is rolled out but some pods are not ready
+	StatusUnReady StatusType = "Unready"
+	// StatusSkipped means the pre-delete job is skipped by the annotation before it is completed
+	StatusSkipped StatusType = "Skipped"
 )
 
 type PhaseType string
@@ -36,6 +40,12 @@ const (
 	PhaseFailed         PhaseType = "Failed"
 	PhaseInitialization PhaseType = "Initialization"
 	PhaseDeploying      PhaseType = "Deploying"
+	PhaseHibernated     PhaseType = "Hibernated"
+	// PhaseDegraded means the workloads are available but below the desired, or the rollout is failed
+	PhaseDegraded PhaseType = "Degraded"
+	// PhaseUpgrading means the workloads are rolling out the changes after the Erda has been ready
+	PhaseUpgrading PhaseType = "Upgrading"
+	PhaseDeleting  PhaseType = "Deleting"
 )
 
 type JobType string
@@ -43,13 +53,24 @@ type JobType string
 const (
 	ErdaPrefix = "erda"
 	PreJobType = "PreJob"
+	// PreDeleteJobType is run when the Erda is deleted, before its resources are torn down, the
+	// teardown is blocked by the failed job unless the jobs are skipped by AnnotationSkipPreDeleteJobs
+	PreDeleteJobType = "PreDelete"
 )
 
+// ErdaFinalizer holds the deleted Erda until its resources are torn down
+const ErdaFinalizer = "erda.erda.cloud/teardown"
+
 const (
 	ErdaJobTypeLabel   = "app.erda.cloud/job-type"
 	ErdaJobNameLabel   = "app.erda.cloud/job-name"
 	ErdaComponentLabel = "app.erda.cloud/component"
 	ErdaOperatorLabel  = "app.erda.cloud/operator"
+	ErdaOrdinalLabel   = "app.erda.cloud/ordinal"
+	ErdaNameLabel      = "app.erda.cloud/erda"
+	ErdaCanaryLabel    = "app.erda.cloud/canary"
+	ErdaColorLabel     = "app.erda.cloud/color"
+	ErdaRevisionLabel  = "app.erda.cloud/revision-hash"
 )
 
 //+kubebuilder:object:root=true
@@ -86,16 +107,54 @@ type ErdaSpec struct {
 	// TODO: 2. How to deal with the job task when it is completed and the user updates the DICE YAML
 	Jobs []Job `yaml:"jobs,omitempty" json:"jobs,omitempty"`
 
+	// Schedules override the replicas of components or hibernate the whole Erda in the time windows
+	Schedules []Schedule `yaml:"schedules,omitempty" json:"schedules,omitempty"`
+
+	// RevisionHistoryLimit is the count of the spec revisions which are kept for the rollback, 10 is default
+	//+kubebuilder:validation:Minimum=1
+	RevisionHistoryLimit *int32 `yaml:"revisionHistoryLimit,omitempty" json:"revisionHistoryLimit,omitempty"`
+
 	//PostJobs     []Job                  `yaml:"postJobs,omitempty" json:"postJobs,omitempty"`
 	// TODO: Finish the addons design
 	// Addons       map[string]Addon       `yaml:"envs,omitempty" json:"addons"`
 }
 
+// Schedule is active from the time matched by Start to the next time matched by End
+type Schedule struct {
+	Name string `yaml:"name" json:"name"`
+	// Start and End are the standard cron expressions with five fields
+	Start string `yaml:"start" json:"start"`
+	End   string `yaml:"end" json:"end"`
+	// TimeZone is the IANA time zone name of the cron expressions, the operator local time zone is default
+	TimeZone string `yaml:"timeZone,omitempty" json:"timeZone,omitempty"`
+	// Hibernate scales all workloads to zero and suspends the unfinished jobs
+	Hibernate bool `yaml:"hibernate,omitempty" json:"hibernate,omitempty"`
+	// Replicas overrides the replicas of the components by component name,
+	// the autoscaler of the overridden components is removed in the time window
+	Replicas map[string]int32 `yaml:"replicas,omitempty" json:"replicas,omitempty"`
+}
+
 // ErdaStatus defines the observed state of Erda
 type ErdaStatus struct {
 	Phase        PhaseType             `yaml:"phase,omitempty" json:"phase,omitempty"`
 	Applications []ApplicationStatus   `yaml:"applications,omitempty" json:"applications,omitempty"`
 	Jobs         map[string]StatusType `json:"jobs,omitempty"`
+	// Reason is the reason of the Degraded and Failed phase
+	Reason string `json:"reason,omitempty"`
+	// Revision is the number of the spec revision which is applied
+	Revision int64 `json:"revision,omitempty"`
+	// ObservedGeneration is the generation of the Erda which is rolled out when it is ready
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// LastTransitionTime is the time when the phase transits last time
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Transitions are the latest phase transitions, the earliest one is dropped beyond the limit
+	Transitions []PhaseTransition `json:"transitions,omitempty"`
+}
+
+type PhaseTransition struct {
+	Phase  PhaseType   `json:"phase"`
+	Reason string      `json:"reason,omitempty"`
+	Time   metav1.Time `json:"time"`
 }
 
 type ApplicationStatus struct {
@@ -107,6 +166,45 @@ type ApplicationStatus struct {
 type ComponentStatus struct {
 	Name   string     `json:"name"`
 	Status StatusType `json:"status"`
+	// Reason is the reason of the Failed status
+	Reason string `json:"reason,omitempty"`
+	// ReadyReplicas and Replicas are the ready and desired pods of the workload
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+	Replicas      int32 `json:"replicas,omitempty"`
+	// Image is the current image of the workload
+	Image string `json:"image,omitempty"`
+	// Restarts is the total restart count of the containers in the pods
+	Restarts int32 `json:"restarts,omitempty"`
+	// ContainerReason and ContainerMessage are the dominant waiting or terminated
+	// reason of the unhealthy containers, such as ImagePullBackOff and CrashLoopBackOff
+	ContainerReason  string           `json:"containerReason,omitempty"`
+	ContainerMessage string           `json:"containerMessage,omitempty"`
+	Canary           *CanaryStatus    `json:"canary,omitempty"`
+	BlueGreen        *BlueGreenStatus `json:"blueGreen,omitempty"`
+}
+
+type BlueGreenStatus struct {
+	// Active is the color which the Service is switched to
+	Active string `json:"active,omitempty"`
+	// RollbackDeadline is the time when the Deployment of the previous color is removed
+	RollbackDeadline *metav1.Time `json:"rollbackDeadline,omitempty"`
+}
+
+type CanaryPhase string
+
+const (
+	CanaryProgressing CanaryPhase = "Progressing"
+	CanaryPaused      CanaryPhase = "Paused"
+	CanaryPromoted    CanaryPhase = "Promoted"
+	CanaryAborted     CanaryPhase = "Aborted"
+)
+
+type CanaryStatus struct {
+	Image string `json:"image"`
+	// Step is the index of the current weight step, it equals to the count of steps after the last step
+	Step   int32       `json:"step"`
+	Weight int32       `json:"weight"`
+	Phase  CanaryPhase `json:"phase"`
 }
 
 func (e *Erda) ComposeOwnerReferences() []metav1.OwnerReference {
@@ -19,22 +19,30 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v4/apis/volumesnapshot/v1"
-	"go.uber.org/zap/zapcore"
 	uberzap "go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/discovery"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	erdaiov1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
 	"github.com/erda-project/erda-operator/pkg/controllers/erda"
+	"github.com/erda-project/erda-operator/pkg/helper"
+	"github.com/erda-project/erda-operator/pkg/utils"
 )
 
 var (
@@ -58,6 +66,13 @@ func main() {
 		qps                         float64
 		burst                       int
 		listenPort                  int
+		ingressClass, routingMode   string
+		gateway                     string
+		tcpServices, udpServices    string
+		clusterDomain               string
+		networkPolicy               bool
+		ingressControllerNamespace  string
+		resyncPeriod                time.Duration
 	)
 
 	// parse flags
@@ -70,6 +85,24 @@ func main() {
 	flag.Float64Var(&qps, "qps", 100, "The maximum QPS to the api-server.")
 	flag.IntVar(&burst, "burst", 100, "The maximum burst for throttle.")
 	flag.IntVar(&listenPort, "listen-port", 9443, "The port the operator listens on.")
+	flag.StringVar(&clusterDomain, "cluster-domain", "",
+		"The cluster DNS domain used in the generated service addresses, it is detected from resolv.conf if empty.")
+	flag.StringVar(&ingressClass, "ingress-class", "",
+		"The default ingress class of generated ingresses, it can be overwritten by the component annotation.")
+	flag.StringVar(&routingMode, "routing-mode", string(erdaiov1beta1.RoutingModeIngress),
+		"The default way to expose component domains, support ingress and gateway.")
+	flag.StringVar(&gateway, "gateway", "",
+		"The default parent Gateway of generated routes in format namespace/name, used by the gateway routing mode.")
+	flag.StringVar(&tcpServices, "ingress-nginx-tcp-services", "",
+		"The ingress-nginx tcp-services ConfigMap in format namespace/name, e.g. ingress-nginx/tcp-services, empty to disable.")
+	flag.StringVar(&udpServices, "ingress-nginx-udp-services", "",
+		"The ingress-nginx udp-services ConfigMap in format namespace/name, e.g. ingress-nginx/udp-services, empty to disable.")
+	flag.BoolVar(&networkPolicy, "network-policy", false,
+		"Generate the network policies of Erda resources by default, it can be overwritten by the Erda annotation.")
+	flag.StringVar(&ingressControllerNamespace, "ingress-controller-namespace", "ingress-nginx",
+		"The namespace of the ingress controller which is allowed to access the ports with domain by the network policies.")
+	flag.DurationVar(&resyncPeriod, "resync-period", erda.DefaultResyncPeriod,
+		"The period to resync the Erda resources which are not ready, the changes of the owned resources are watched.")
 
 	opts := zap.Options{
 		Development:     debug,
@@ -88,6 +121,33 @@ func main() {
 	rc.Burst = burst
 	rc.QPS = float32(qps)
 
+	if clusterDomain == "" {
+		clusterDomain = utils.DetectClusterDomain()
+	}
+	setupLog.Info("cluster domain", "domain", clusterDomain)
+
+	// detect the served ingress api version
+	discoveryClient := discovery.NewDiscoveryClientForConfigOrDie(rc)
+	ingressAPIVersion, err := utils.DetectServedResourceVersion(discoveryClient,
+		"ingresses", helper.IngressAPIVersionV1, helper.IngressAPIVersionNetworkingV1beta1,
+		helper.IngressAPIVersionExtensionsV1beta1)
+	if err != nil {
+		setupLog.Error(err, "unable to detect ingress api version")
+		os.Exit(1)
+	}
+	setupLog.Info("detected ingress api version", "version", ingressAPIVersion)
+
+	// detect the served gateway routes, they are watched only if their CRDs are installed
+	var gatewayRouteGVKs []schema.GroupVersionKind
+	for _, gvk := range []schema.GroupVersionKind{helper.HTTPRouteGVK, helper.GRPCRouteGVK} {
+		resource := strings.ToLower(gvk.Kind) + "s"
+		if _, err := utils.DetectServedResourceVersion(discoveryClient, resource,
+			gvk.GroupVersion().String()); err == nil {
+			gatewayRouteGVKs = append(gatewayRouteGVKs, gvk)
+		}
+	}
+	setupLog.Info("detected gateway routes", "kinds", gatewayRouteGVKs)
+
 	// new controller manager
 	mgr, err := ctrl.NewManager(rc, ctrl.Options{
 		Scheme:                 scheme,
@@ -96,6 +156,10 @@ func main() {
 		MetricsBindAddress:     metricsAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "8d6f4370.erda.cloud",
+		// only the resources labeled by the operator are watched, see ErdaReconciler.SetupWithManager
+		ClientDisableCacheFor: []client.Object{
+			&corev1.ConfigMap{}, &corev1.Secret{}, &corev1.PersistentVolumeClaim{},
+		},
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -103,9 +167,24 @@ func main() {
 	}
 
 	if err = (&erda.ErdaReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-		Log:    ctrl.Log.WithName("controllers").WithName("Erda"),
+		Client:    mgr.GetClient(),
+		APIReader: mgr.GetAPIReader(),
+		Scheme:    mgr.GetScheme(),
+		Log:       ctrl.Log.WithName("controllers").WithName("Erda"),
+		Recorder:  mgr.GetEventRecorderFor("erda-operator"),
+		Options: erda.Options{
+			ClusterDomain:              clusterDomain,
+			IngressClassName:           ingressClass,
+			IngressAPIVersion:          ingressAPIVersion,
+			RoutingMode:                erdaiov1beta1.RoutingMode(routingMode),
+			Gateway:                    gateway,
+			GatewayRouteGVKs:           gatewayRouteGVKs,
+			TCPServicesConfigMap:       tcpServices,
+			UDPServicesConfigMap:       udpServices,
+			NetworkPolicy:              networkPolicy,
+			IngressControllerNamespace: ingressControllerNamespace,
+			ResyncPeriod:               resyncPeriod,
+		},
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Erda")
 		os.Exit(1)
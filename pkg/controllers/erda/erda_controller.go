@@ -16,30 +16,108 @@ package erda
 
 import (
 	"context"
+	"fmt"
+	"reflect"
 	"time"
 
 	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlbuilder "sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 	"k8s.io/apimachinery/pkg/api/errors"
 
 	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+	"github.com/erda-project/erda-operator/pkg/helper"
 )
 
 var (
-	requeueTime = 5 * time.Second
+	// DefaultResyncPeriod is the period to resync the Erda which is not ready, the progress
+	// of the owned resources is observed by the watches
+	DefaultResyncPeriod = 5 * time.Minute
+)
+
+// the reasons of the Events which are recorded on the Erda and the generated objects
+const (
+	// EventReasonDesiredStateChanged is the reason of the Event recorded on the generated object
+	// whose desired state is changed
+	EventReasonDesiredStateChanged = "DesiredStateChanged"
+	EventReasonCreated             = "Created"
+	EventReasonUpdated             = "Updated"
+	EventReasonDeleted             = "Deleted"
+	EventReasonResized             = "Resized"
+	EventReasonRolledBack          = "RolledBack"
+	EventReasonJobStarted          = "JobStarted"
+	EventReasonJobCompleted        = "JobCompleted"
+	EventReasonJobFailed           = "JobFailed"
+	EventReasonJobSkipped          = "JobSkipped"
+	EventReasonConfigurationFailed = "ConfigurationFailed"
+	EventReasonInvalidAnnotation   = "InvalidAnnotation"
+	EventReasonPhaseChanged        = "PhaseChanged"
+	EventReasonReconcileFailed     = "ReconcileFailed"
+	EventReasonInvalidRollout      = "InvalidRollout"
+	EventReasonInvalidSchedule     = "InvalidSchedule"
+	EventReasonRollbackFailed      = "RollbackFailed"
 )
 
 // ErdaReconciler reconciles a Erda object
 type ErdaReconciler struct {
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
-	options
+	// APIReader reads the resources which are rarely read without the cache, such as the ReplicaSets
+	APIReader client.Reader
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	Recorder  record.EventRecorder
+	Options
 }
 
-type options struct {
+// Options is the operator level settings which apply to all Erda resources
+type Options struct {
+	// ClusterDomain is the cluster DNS domain used in the generated service addresses
+	ClusterDomain string
+	// IngressClassName is the default ingress class of generated ingresses
+	IngressClassName string
+	// IngressAPIVersion is the served ingress api version detected at startup
+	IngressAPIVersion string
+	// RoutingMode is the default way to expose component domains, ingress or gateway
+	RoutingMode erdav1beta1.RoutingMode
+	// Gateway is the default parent Gateway of generated routes, in format namespace/name
+	Gateway string
+	// GatewayRouteGVKs are the gateway route kinds which are served and watched
+	GatewayRouteGVKs []schema.GroupVersionKind
+	// TCPServicesConfigMap and UDPServicesConfigMap are the ingress-nginx stream
+	// ConfigMaps in format namespace/name, the exposed ports will be registered in them
+	TCPServicesConfigMap string
+	UDPServicesConfigMap string
+	// NetworkPolicy generates the network policies of Erda resources by default,
+	// it can be overwritten by the Erda annotation
+	NetworkPolicy bool
+	// IngressControllerNamespace is the namespace of the ingress controller which
+	// is allowed to access the ports with domain by the network policies
+	IngressControllerNamespace string
+	// ResyncPeriod is the period to resync the Erda which is not ready, DefaultResyncPeriod if zero
+	ResyncPeriod time.Duration
 }
 
 //+kubebuilder:rbac:groups=core.erda.cloud,resources=erdas,verbs=get;list;watch;create;update;patch;delete
@@ -60,7 +138,78 @@ func (r *ErdaReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 	}
 	references := erda.ComposeOwnerReferences()
 
-	if len(erda.Spec.Jobs) > 0 {
+	// the phase transition is recorded after the status is updated in this round
+	var phase erdav1beta1.PhaseType
+	if erda.Status != nil {
+		phase = erda.Status.Phase
+	}
+	defer r.recordPhaseTransition(&erda, phase)
+
+	// the resources are torn down in order before the finalizer is removed, and the
+	// owned resources which are left are removed by the garbage collector
+	if !erda.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(&erda, erdav1beta1.ErdaFinalizer) {
+			return ctrl.Result{}, nil
+		}
+		finished, err := r.TeardownErda(ctx, &erda)
+		if err != nil {
+			log.Error(err, "teardown error")
+			r.recordEvent(&erda, corev1.EventTypeWarning, EventReasonReconcileFailed, "teardown error: %v", err)
+			return ctrl.Result{Requeue: true}, client.IgnoreNotFound(err)
+		}
+		if !finished {
+			return ctrl.Result{RequeueAfter: teardownRequeuePeriod}, nil
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(&erda, erdav1beta1.ErdaFinalizer) {
+		controllerutil.AddFinalizer(&erda, erdav1beta1.ErdaFinalizer)
+		if err := r.Update(ctx, &erda); err != nil {
+			return ctrl.Result{Requeue: true}, client.IgnoreNotFound(err)
+		}
+	}
+
+	// the Erda is reconciled again with the restored spec after it is updated
+	if erda.Annotations[erdav1beta1.AnnotationRollbackTo] != "" {
+		if err := r.RollbackErda(ctx, &erda); err != nil {
+			log.Error(err, "rollback error")
+			return ctrl.Result{Requeue: true}, client.IgnoreNotFound(err)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.SyncCanaryAborts(ctx, &erda); err != nil {
+		log.Error(err, "sync canary aborts error")
+		return ctrl.Result{Requeue: true}, client.IgnoreNotFound(err)
+	}
+
+	if err := r.SyncRevision(ctx, &erda, references); err != nil {
+		log.Error(err, "sync revision error")
+		return ctrl.Result{Requeue: true}, client.IgnoreNotFound(err)
+	}
+
+	// the invalid schedule is not retried until the spec is changed
+	state, err := helper.ComposeScheduleState(&erda, time.Now())
+	if err != nil {
+		log.Error(err, "invalid schedule")
+		r.recordEvent(&erda, corev1.EventTypeWarning, EventReasonInvalidSchedule, "invalid schedule: %v", err)
+		if erda.Status == nil {
+			erda.Status = &erdav1beta1.ErdaStatus{}
+		}
+		helper.TransitPhase(erda.Status, erdav1beta1.PhaseFailed, fmt.Sprintf("invalid schedule: %v", err), time.Now())
+		return ctrl.Result{}, client.IgnoreNotFound(r.Status().Update(ctx, &erda))
+	}
+
+	// the unfinished jobs are suspended in hibernation and resumed after it
+	if len(erda.Spec.Jobs) > 0 && erda.Status != nil &&
+		(state.Hibernated || erda.Status.Phase == erdav1beta1.PhaseHibernated) {
+		if err := r.SuspendJobs(ctx, &erda, state.Hibernated); err != nil {
+			return ctrl.Result{Requeue: true}, client.IgnoreNotFound(err)
+		}
+	}
+
+	if helper.HasPreJobs(&erda) && !state.Hibernated {
 		if err := r.ReconcileJob(ctx, &erda, references); err != nil {
 			if errors.IsConflict(err) {
 				return ctrl.Result{Requeue: true}, nil
@@ -69,29 +218,190 @@ func (r *ErdaReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		}
 		switch erda.Status.Phase {
 		case erdav1beta1.PhaseInitialization:
-			return ctrl.Result{Requeue: true, RequeueAfter: requeueTime}, nil
+			return ctrl.Result{Requeue: true, RequeueAfter: r.composeResyncPeriod()}, nil
 		case erdav1beta1.PhaseFailed:
-			return ctrl.Result{}, nil
+			return ctrl.Result{}, r.SyncRevisionOutcome(ctx, &erda, helper.RevisionOutcomeFailed)
 		}
 	}
 
-	if err := r.ReconcileApplication(ctx, &erda, references); err != nil {
+	requeueTime, err := r.ReconcileApplication(ctx, &erda, references, state)
+	if err != nil {
 		if errors.IsConflict(err) {
 			return ctrl.Result{Requeue: true}, nil
 		}
+		r.recordEvent(&erda, corev1.EventTypeWarning, EventReasonReconcileFailed, "reconcile application error: %v", err)
 		return ctrl.Result{Requeue: true}, client.IgnoreNotFound(err)
 	}
 
-	if erda.Status.Phase != erdav1beta1.PhaseReady {
-		return ctrl.Result{Requeue: true, RequeueAfter: requeueTime}, nil
+	result := ctrl.Result{}
+	if erda.Status.Phase != erdav1beta1.PhaseReady && erda.Status.Phase != erdav1beta1.PhaseHibernated {
+		result = ctrl.Result{Requeue: true, RequeueAfter: r.composeResyncPeriod()}
 	}
+	// the canary pauses and the progress deadlines are not observed by the watches
+	result = composeRequeueResult(result, requeueTime)
+	// the previous colors of the blue-green components are removed at the rollback deadlines
+	result = composeRequeueResult(result, helper.ComposeBlueGreenRequeueTime(&erda, time.Now()))
+	return composeScheduleResult(result, state), nil
+}
 
-	return ctrl.Result{}, nil
+func (r *ErdaReconciler) composeResyncPeriod() time.Duration {
+	if r.ResyncPeriod > 0 {
+		return r.ResyncPeriod
+	}
+	return DefaultResyncPeriod
+}
+
+// recordEvent records the Event on the object, nothing is recorded without the recorder
+func (r *ErdaReconciler) recordEvent(obj runtime.Object, eventType, reason, messageFmt string, args ...interface{}) {
+	if r.Recorder == nil || obj == nil {
+		return
+	}
+	r.Recorder.Eventf(obj, eventType, reason, messageFmt, args...)
+}
+
+// recordPhaseTransition records the Event when the phase of the Erda is changed from the phase,
+// the failed and the degraded phases are warned
+func (r *ErdaReconciler) recordPhaseTransition(erda *erdav1beta1.Erda, phase erdav1beta1.PhaseType) {
+	if erda.Status == nil || erda.Status.Phase == phase {
+		return
+	}
+	eventType := corev1.EventTypeNormal
+	if erda.Status.Phase == erdav1beta1.PhaseFailed || erda.Status.Phase == erdav1beta1.PhaseDegraded {
+		eventType = corev1.EventTypeWarning
+	}
+	message := fmt.Sprintf("phase is changed from %s to %s", phase, erda.Status.Phase)
+	if phase == "" {
+		message = fmt.Sprintf("phase is changed to %s", erda.Status.Phase)
+	}
+	if erda.Status.Reason != "" {
+		message = fmt.Sprintf("%s: %s", message, erda.Status.Reason)
+	}
+	r.recordEvent(erda, eventType, EventReasonPhaseChanged, message)
+}
+
+// composeEventObject returns the reference of the Erda which owns the resources of the component,
+// the Events of the component are recorded on the Erda to be described with it
+func composeEventObject(namespace string, references []metav1.OwnerReference) runtime.Object {
+	if len(references) == 0 {
+		return nil
+	}
+	return &corev1.ObjectReference{
+		APIVersion: erdav1beta1.GroupVersion.String(),
+		Kind:       "Erda",
+		Name:       references[0].Name,
+		Namespace:  namespace,
+		UID:        references[0].UID,
+	}
+}
+
+// composeKind returns the kind of the object by the scheme, the typed objects carry no kind
+func (r *ErdaReconciler) composeKind(obj runtime.Object) string {
+	if gvk, err := apiutil.GVKForObject(obj, r.Scheme); err == nil {
+		return gvk.Kind
+	}
+	return obj.GetObjectKind().GroupVersionKind().Kind
 }
 
 // SetupWithManager sets up the controller with the Manager.
+// The Erda is reconciled by the changes of the resources which are owned by it, and the changes
+// of the PersistentVolumeClaims and the configurations which are applied for its components, and
+// the Erda with the network policies is also reconciled when the node addresses change.
+// The ConfigMaps, the Secrets and the PersistentVolumeClaims are read from the API server, only
+// the ones labeled by the operator are watched, so the others of the cluster are not cached.
 func (r *ErdaReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	clientSet, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+	factory := informers.NewSharedInformerFactoryWithOptions(clientSet, 0,
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = helper.ComposeWatchedSelector()
+		}))
+	configMaps := factory.Core().V1().ConfigMaps().Informer()
+	secrets := factory.Core().V1().Secrets().Informer()
+	claims := factory.Core().V1().PersistentVolumeClaims().Informer()
+	if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		factory.Start(ctx.Done())
+		<-ctx.Done()
+		return nil
+	})); err != nil {
+		return err
+	}
+
+	owner := &handler.EnqueueRequestForOwner{OwnerType: &erdav1beta1.Erda{}}
+	labeler := handler.EnqueueRequestsFromMapFunc(mapLabeledResource)
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&erdav1beta1.Erda{}).
-		Complete(r)
+		Watches(&source.Kind{Type: &appsv1.Deployment{}}, owner).
+		Watches(&source.Kind{Type: &appsv1.StatefulSet{}}, owner).
+		Watches(&source.Kind{Type: &appsv1.DaemonSet{}}, owner).
+		Watches(&source.Kind{Type: &appsv1.ControllerRevision{}}, owner).
+		Watches(&source.Kind{Type: &batchv1.Job{}}, owner).
+		Watches(&source.Kind{Type: &corev1.Service{}}, owner).
+		Watches(&source.Kind{Type: helper.NewIngress(r.IngressAPIVersion)}, owner).
+		Watches(&source.Kind{Type: &autoscalingv2beta2.HorizontalPodAutoscaler{}}, owner).
+		Watches(&source.Kind{Type: &policyv1beta1.PodDisruptionBudget{}}, owner).
+		Watches(&source.Kind{Type: &networkingv1.NetworkPolicy{}}, owner).
+		Watches(&source.Informer{Informer: configMaps}, labeler).
+		Watches(&source.Informer{Informer: secrets}, labeler).
+		Watches(&source.Informer{Informer: claims}, labeler).
+		// the host CIDRs of the network policies are composed of the node addresses
+		Watches(&source.Kind{Type: &corev1.Node{}}, handler.EnqueueRequestsFromMapFunc(r.mapNode),
+			ctrlbuilder.WithPredicates(predicate.Funcs{UpdateFunc: isNodeAddressChanged}))
+	// the gateway routes are only watched when their CRDs are installed
+	for _, gvk := range r.GatewayRouteGVKs {
+		route := &unstructured.Unstructured{}
+		route.SetGroupVersionKind(gvk)
+		builder = builder.Watches(&source.Kind{Type: route}, owner)
+	}
+	return builder.Complete(r)
+}
+
+// mapNode maps the Node to the Erdas whose network policies are enabled, the policies allow
+// the host network peers by the node addresses
+func (r *ErdaReconciler) mapNode(obj client.Object) []reconcile.Request {
+	erdas := &erdav1beta1.ErdaList{}
+	if err := r.List(context.Background(), erdas); err != nil {
+		r.Log.Error(err, "list erda error", "node", obj.GetName())
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for i := range erdas.Items {
+		if erdas.Items[i].Spec == nil || !helper.IsNetworkPolicyEnabled(&erdas.Items[i], r.NetworkPolicy) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+			Name:      erdas.Items[i].Name,
+			Namespace: erdas.Items[i].Namespace,
+		}})
+	}
+	return requests
+}
+
+// isNodeAddressChanged filters the updates of the Node which do not change its addresses,
+// such as the heartbeats of the conditions
+func isNodeAddressChanged(e event.UpdateEvent) bool {
+	oldNode, ok := e.ObjectOld.(*corev1.Node)
+	if !ok {
+		return true
+	}
+	newNode, ok := e.ObjectNew.(*corev1.Node)
+	if !ok {
+		return true
+	}
+	return !reflect.DeepEqual(helper.ComposeNodeCIDRs([]corev1.Node{*oldNode}),
+		helper.ComposeNodeCIDRs([]corev1.Node{*newNode}))
+}
+
+// mapLabeledResource maps the PersistentVolumeClaim or the configuration to the Erda named by
+// its label in the same namespace, they are not owned by the Erda and outlive it
+func mapLabeledResource(obj client.Object) []reconcile.Request {
+	name := obj.GetLabels()[erdav1beta1.ErdaNameLabel]
+	if name == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{
+		Name:      name,
+		Namespace: obj.GetNamespace(),
+	}}}
 }
@@ -0,0 +1,53 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package erda
+
+import (
+	"context"
+
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+	"github.com/erda-project/erda-operator/pkg/helper"
+	"github.com/erda-project/erda-operator/pkg/utils"
+)
+
+// CreateOrUpdateHorizontalPodAutoscaler reconciles the autoscaler of the component,
+// it is deleted when the autoscaling is disabled
+func (r *ErdaReconciler) CreateOrUpdateHorizontalPodAutoscaler(ctx context.Context,
+	component *erdav1beta1.Component, owners []metav1.OwnerReference) error {
+	key := types.NamespacedName{Name: component.Name, Namespace: component.Namespace}
+	if !helper.IsAutoscalingEnabled(component) {
+		return r.DeleteHorizontalPodAutoscaler(ctx, key)
+	}
+
+	newHPA := helper.ComposeHorizontalPodAutoscaler(component, owners)
+	return r.applyResource(ctx, newHPA)
+}
+
+func (r *ErdaReconciler) DeleteHorizontalPodAutoscaler(ctx context.Context, key types.NamespacedName) error {
+	deleteOptions := client.DeleteOptions{}
+	deleteOptions.PropagationPolicy = utils.ConvertDeletePropagationToPoint(metav1.DeletePropagationBackground)
+
+	hpa := &autoscalingv2beta2.HorizontalPodAutoscaler{}
+	if err := r.Get(ctx, key, hpa); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	r.Log.Info("autoscaler resource need to be deleted", "name", key.Name, "namespace", key.Namespace)
+	return client.IgnoreNotFound(r.Delete(ctx, hpa, &deleteOptions))
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package erda
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+	"github.com/erda-project/erda-operator/pkg/helper"
+	"github.com/erda-project/erda-operator/pkg/utils"
+)
+
+// ReconcileBlueGreen releases the component to the Deployment of the other color when the pod
+// template changes, and switches to it after it is available, the reverted component is switched
+// back to the previous color instantly. It returns the labels which the Service selects besides the
+// component labels and whether the Deployments are changed, the Service is pinned to the pods of the
+// Deployment before blue-green until the first color is switched to
+func (r *ErdaReconciler) ReconcileBlueGreen(ctx context.Context,
+	component *erdav1beta1.Component, owners []metav1.OwnerReference) (map[string]string, bool, error) {
+	active, changed, err := r.reconcileBlueGreen(ctx, component, owners)
+	if err != nil || active != "" {
+		return map[string]string{erdav1beta1.ErdaColorLabel: active}, changed, err
+	}
+	selector, err := r.composePreBlueGreenSelector(ctx, types.NamespacedName{
+		Name:      component.Name,
+		Namespace: component.Namespace,
+	})
+	return selector, changed, err
+}
+
+// composePreBlueGreenSelector returns the labels which only select the pods of the current revision
+// of the Deployment before blue-green, since the pods of the colors also match the component labels.
+// No labels are returned without the Deployment, and it fails if the current ReplicaSet is not found
+// rather than selecting the pods of the colors
+func (r *ErdaReconciler) composePreBlueGreenSelector(ctx context.Context,
+	key types.NamespacedName) (map[string]string, error) {
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, key, deployment); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	// the ReplicaSets are only read in the first blue-green release, they are not cached
+	replicaSets := &appsv1.ReplicaSetList{}
+	if err := r.APIReader.List(ctx, replicaSets, client.InNamespace(key.Namespace),
+		client.MatchingLabels(deployment.Spec.Selector.MatchLabels)); err != nil {
+		return nil, err
+	}
+	hash := helper.ComposeCurrentPodTemplateHash(deployment, replicaSets.Items)
+	if hash == "" {
+		return nil, errors.Errorf("current replica set of deployment %s is not found", key.Name)
+	}
+	return map[string]string{appsv1.DefaultDeploymentUniqueLabelKey: hash}, nil
+}
+
+func (r *ErdaReconciler) reconcileBlueGreen(ctx context.Context,
+	component *erdav1beta1.Component, owners []metav1.OwnerReference) (string, bool, error) {
+	deployments := map[string]*appsv1.Deployment{}
+	for _, color := range []string{helper.BlueGreenBlue, helper.BlueGreenGreen} {
+		deployment := &appsv1.Deployment{}
+		key := types.NamespacedName{Name: helper.ComposeBlueGreenName(component.Name, color), Namespace: component.Namespace}
+		if err := r.Get(ctx, key, deployment); err != nil {
+			if !k8sErrors.IsNotFound(err) {
+				return "", false, err
+			}
+			continue
+		}
+		deployments[color] = deployment
+	}
+
+	active := helper.ComposeBlueGreenActiveColor(deployments)
+	target := helper.ComposeBlueGreenOtherColor(active)
+	if active != "" && helper.IsBlueGreenTemplateEqual(deployments[active],
+		helper.ComposeBlueGreenDeployment(component, active, owners)) {
+		target = active
+	}
+
+	newDeployment := helper.ComposeBlueGreenDeployment(component, target, owners)
+	deployment, ok := deployments[target]
+	if !ok {
+		r.Log.Info("blue-green release", "name", component.Name, "namespace", component.Namespace, "color", target)
+		return active, true, r.applyResource(ctx, newDeployment)
+	}
+	// the switch time is kept, the previous color is switched back without waiting
+	if switchTime, ok := deployment.Annotations[erdav1beta1.AnnotationBlueGreenSwitchTime]; ok {
+		newDeployment.Annotations = map[string]string{erdav1beta1.AnnotationBlueGreenSwitchTime: switchTime}
+	}
+	if err := r.applyResource(ctx, newDeployment); err != nil {
+		return "", false, err
+	}
+	if newDeployment.Generation != deployment.Generation {
+		return active, true, nil
+	}
+
+	if target != active {
+		if r.getWorkLoadStatus(newDeployment) != erdav1beta1.StatusReady {
+			return active, false, nil
+		}
+		r.Log.Info("blue-green switch", "name", component.Name, "namespace", component.Namespace,
+			"from", active, "to", target)
+		newDeployment = helper.ComposeBlueGreenDeployment(component, target, owners)
+		newDeployment.Annotations = map[string]string{
+			erdav1beta1.AnnotationBlueGreenSwitchTime: time.Now().UTC().Format(time.RFC3339),
+		}
+		return target, true, r.applyResource(ctx, newDeployment)
+	}
+
+	// the Deployment before the component is released by blue-green is replaced by the active color
+	if err := r.deleteBlueGreenDeployment(ctx, types.NamespacedName{
+		Name:      component.Name,
+		Namespace: component.Namespace,
+	}); err != nil {
+		return "", false, err
+	}
+	// the previous color is kept for the rollback in the window
+	previous := helper.ComposeBlueGreenOtherColor(active)
+	if _, ok := deployments[previous]; ok &&
+		time.Since(helper.ComposeBlueGreenSwitchTime(deployments[active])) >= helper.ComposeBlueGreenRollbackWindow(component) {
+		return active, false, r.deleteBlueGreenDeployment(ctx, types.NamespacedName{
+			Name:      helper.ComposeBlueGreenName(component.Name, previous),
+			Namespace: component.Namespace,
+		})
+	}
+	return active, false, nil
+}
+
+// deleteBlueGreenDeployment only deletes the Deployment, the Service and the other resources
+// of the component are shared by the colors
+func (r *ErdaReconciler) deleteBlueGreenDeployment(ctx context.Context, key types.NamespacedName) error {
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, key, deployment); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	deleteOptions := client.DeleteOptions{}
+	deleteOptions.PropagationPolicy = utils.ConvertDeletePropagationToPoint(metav1.DeletePropagationBackground)
+	r.Log.Info("blue-green deployment need to be deleted", "name", key.Name, "namespace", key.Namespace)
+	return client.IgnoreNotFound(r.Delete(ctx, deployment, &deleteOptions))
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package erda
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+	"github.com/erda-project/erda-operator/pkg/helper"
+	"github.com/erda-project/erda-operator/pkg/utils"
+)
+
+// ReconcileCanary runs the canary release of the Stateless component. The canary Deployment,
+// Service and Ingress are removed when the canary is aborted or removed from spec, and when
+// the component Deployment is ready after the component image is updated to the canary image.
+func (r *ErdaReconciler) ReconcileCanary(ctx context.Context, erda *erdav1beta1.Erda,
+	component *erdav1beta1.Component, references []metav1.OwnerReference) error {
+	key := types.NamespacedName{Name: helper.ComposeCanaryName(component.Name), Namespace: component.Namespace}
+	if !helper.IsCanaryEnabled(component) || helper.IsCanaryAborted(erda, component) {
+		if helper.IsCanaryFinished(component) {
+			// the canary keeps serving until the component is rolled to the canary image
+			stable := &appsv1.Deployment{}
+			err := r.Get(ctx, types.NamespacedName{Name: component.Name, Namespace: component.Namespace}, stable)
+			if err != nil {
+				return client.IgnoreNotFound(err)
+			}
+			if r.getWorkLoadStatus(stable) != erdav1beta1.StatusReady {
+				return nil
+			}
+		}
+		return r.DeleteCanary(ctx, key)
+	}
+	// the traffic of the domains is shifted to the canary by the ingress
+	if helper.ComposeRoutingMode(component, r.RoutingMode) != erdav1beta1.RoutingModeIngress &&
+		helper.HasDomain(component) {
+		return errors.Errorf("canary of component %s requires the ingress routing mode", component.Name)
+	}
+
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, key, deployment); err != nil {
+		if !k8sErrors.IsNotFound(err) {
+			return err
+		}
+		deployment = nil
+	}
+	promote := helper.ParseCanaryComponents(erda.Annotations[erdav1beta1.AnnotationCanaryPromote])
+	step, stepTime := helper.ComposeCanaryStep(component.Canary, deployment, promote, component.Name, time.Now())
+
+	canary := helper.ComposeCanaryComponent(component)
+	newDeployment := helper.ComposeDeployment(canary, references)
+	newDeployment.Annotations = utils.MergeMap(newDeployment.Annotations, map[string]string{
+		erdav1beta1.AnnotationCanaryStep:     strconv.Itoa(int(step)),
+		erdav1beta1.AnnotationCanaryStepTime: stepTime.UTC().Format(time.RFC3339),
+	})
+	if deployment == nil ||
+		deployment.Annotations[erdav1beta1.AnnotationCanaryStep] != newDeployment.Annotations[erdav1beta1.AnnotationCanaryStep] {
+		r.Log.Info("canary step", "name", key.Name, "namespace", key.Namespace, "step", step)
+	}
+	if err := r.applyResource(ctx, newDeployment); err != nil {
+		return err
+	}
+
+	if len(canary.Network.ServiceDiscovery) == 0 {
+		return nil
+	}
+	if err := r.CreateOrUpdateKubernetesService(ctx, canary, references); err != nil {
+		return err
+	}
+	for _, sd := range canary.Network.ServiceDiscovery {
+		if sd.Domain != "" {
+			return r.createOrUpdateIngress(ctx, helper.ComposeCanaryIngress(r.IngressAPIVersion, canary, references,
+				r.IngressClassName, helper.ComposeCanaryWeight(component.Canary, step)))
+		}
+	}
+	return nil
+}
+
+// SyncCanaryAborts resolves the components in the abort annotation of the Erda to the aborted
+// canary images, the abort annotation is removed and the aborted images are recorded
+func (r *ErdaReconciler) SyncCanaryAborts(ctx context.Context, erda *erdav1beta1.Erda) error {
+	aborted := helper.ComposeCanaryAborted(erda)
+	if _, ok := erda.Annotations[erdav1beta1.AnnotationCanaryAbort]; !ok &&
+		erda.Annotations[erdav1beta1.AnnotationCanaryAborted] == aborted {
+		return nil
+	}
+	delete(erda.Annotations, erdav1beta1.AnnotationCanaryAbort)
+	if aborted == "" {
+		delete(erda.Annotations, erdav1beta1.AnnotationCanaryAborted)
+	} else {
+		erda.Annotations[erdav1beta1.AnnotationCanaryAborted] = aborted
+	}
+	r.Log.Info("canary aborts need to be recorded", "name", erda.Name, "namespace", erda.Namespace,
+		"aborted", aborted)
+	return r.Update(ctx, erda)
+}
+
+// DeleteCanary deletes the canary Deployment, Service and Ingress, the ingress
+// is deleted first to shift the traffic back to the component, the Deployment is
+// deleted in the foreground to be kept until its pods are terminated
+func (r *ErdaReconciler) DeleteCanary(ctx context.Context, key types.NamespacedName) error {
+	if err := r.DeleteIngress(key); err != nil {
+		return err
+	}
+	if err := r.DeleteKubernetesService(key); err != nil {
+		return err
+	}
+
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, key, deployment); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	deleteOptions := client.DeleteOptions{}
+	deleteOptions.PropagationPolicy = utils.ConvertDeletePropagationToPoint(metav1.DeletePropagationForeground)
+	r.Log.Info("canary resource need to be deleted", "name", key.Name, "namespace", key.Namespace)
+	return client.IgnoreNotFound(r.Delete(ctx, deployment, &deleteOptions))
+}
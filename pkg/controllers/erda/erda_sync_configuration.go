@@ -17,71 +17,41 @@ package erda
 import (
 	"context"
 	"fmt"
-	"reflect"
 
 	corev1 "k8s.io/api/core/v1"
-
-	"k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+	"github.com/erda-project/erda-operator/pkg/helper"
 )
 
-func (r *ErdaReconciler) SyncConfigurations(component *erdav1beta1.Component) {
+// SyncConfigurations applies the configurations which carry the data, the configurations without
+// data refer to the existing ConfigMaps and Secrets which are not managed by the operator, and the
+// failures are recorded as the Events of the Erda
+func (r *ErdaReconciler) SyncConfigurations(erda *erdav1beta1.Erda, component *erdav1beta1.Component) {
 	for _, config := range component.Configurations {
-		var (
-			cfg        client.Object
-			newCfg     client.Object
-			data       map[string][]byte
-			stringData map[string]string
-		)
+		if len(config.Data) == 0 && len(config.StringData) == 0 {
+			continue
+		}
 
+		var cfg client.Object
 		switch config.Type {
 		case erdav1beta1.ConfigurationConfigMap:
-			cfg = &corev1.ConfigMap{}
+			cfg = ComposeConfigMap(&config, component.Namespace)
 		case erdav1beta1.ConfigurationSecret:
-			cfg = &corev1.Secret{}
-		}
-
-		err := r.Client.Get(context.Background(), types.NamespacedName{
-			Namespace: component.Namespace,
-			Name:      config.Name,
-		}, cfg)
-
-		switch v := cfg.(type) {
-		case *corev1.ConfigMap:
-			data = v.BinaryData
-			stringData = v.Data
-			newCfg = ComposeConfigMap(&config, component.Namespace)
-		case *corev1.Secret:
-			data = v.Data
-			stringData = v.StringData
-			newCfg = ComposeSecret(&config, component.Namespace)
-		}
-
-		if client.IgnoreNotFound(err) != nil {
-			r.Log.Error(err, fmt.Sprintf("get secret %s error", cfg.GetName()))
+			cfg = ComposeSecret(&config, component.Namespace)
+		default:
 			continue
 		}
+		cfg.SetLabels(helper.ComposeDeclaredLabels(erda.Name))
 
-		if errors.IsNotFound(err) && (data != nil || stringData != nil) {
-			createErr := r.Create(context.Background(), newCfg)
-			if createErr != nil {
-				r.Log.Error(createErr, fmt.Sprintf("create secret %s error", newCfg.GetName()))
-				continue
-			}
-		}
-
-		if !DiffConfiguration(cfg, newCfg) {
-			updateErr := r.Update(context.Background(), newCfg)
-			if updateErr != nil {
-				r.Log.Error(updateErr, fmt.Sprintf("create secret %s error", newCfg.GetName()))
-				continue
-			}
+		if err := r.applyResource(context.Background(), cfg); err != nil {
+			r.Log.Error(err, fmt.Sprintf("apply configuration %s error", cfg.GetName()))
+			r.recordEvent(erda, corev1.EventTypeWarning, EventReasonConfigurationFailed,
+				"apply %s %s of component %s error: %v", config.Type, cfg.GetName(), component.Name, err)
+			continue
 		}
 	}
-	return
 }
 
 func ComposeConfigMap(config *erdav1beta1.Configuration, namespace string) *corev1.ConfigMap {
@@ -101,25 +71,3 @@ func ComposeSecret(config *erdav1beta1.Configuration, namespace string) *corev1.
 	secret.StringData = config.StringData
 	return &secret
 }
-
-func DiffConfiguration(oldCfg, newCfg client.Object) bool {
-	if oldSecret, ok := oldCfg.(*corev1.Secret); ok {
-		newSecret := newCfg.(*corev1.Secret)
-		if !reflect.DeepEqual(oldSecret.Data, newSecret.Data) {
-			return true
-		}
-		if !reflect.DeepEqual(oldSecret.StringData, newSecret.StringData) {
-			return true
-		}
-	}
-	if oldConfigMap, ok := oldCfg.(*corev1.ConfigMap); ok {
-		newConfigMap := newCfg.(*corev1.ConfigMap)
-		if !reflect.DeepEqual(oldConfigMap.Data, newConfigMap.Data) {
-			return true
-		}
-		if !reflect.DeepEqual(oldConfigMap.BinaryData, newConfigMap.BinaryData) {
-			return true
-		}
-	}
-	return false
-}
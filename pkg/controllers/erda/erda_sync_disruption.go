@@ -0,0 +1,53 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package erda
+
+import (
+	"context"
+
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+	"github.com/erda-project/erda-operator/pkg/helper"
+	"github.com/erda-project/erda-operator/pkg/utils"
+)
+
+// CreateOrUpdatePodDisruptionBudget reconciles the PodDisruptionBudget of the component,
+// it is deleted when the component has no more than one replica
+func (r *ErdaReconciler) CreateOrUpdatePodDisruptionBudget(ctx context.Context,
+	component *erdav1beta1.Component, owners []metav1.OwnerReference) error {
+	key := types.NamespacedName{Name: component.Name, Namespace: component.Namespace}
+	if !helper.IsDisruptionBudgetRequired(component) {
+		return r.DeletePodDisruptionBudget(ctx, key)
+	}
+
+	newPDB := helper.ComposePodDisruptionBudget(component, owners)
+	return r.applyResource(ctx, newPDB)
+}
+
+func (r *ErdaReconciler) DeletePodDisruptionBudget(ctx context.Context, key types.NamespacedName) error {
+	deleteOptions := client.DeleteOptions{}
+	deleteOptions.PropagationPolicy = utils.ConvertDeletePropagationToPoint(metav1.DeletePropagationBackground)
+
+	pdb := &policyv1beta1.PodDisruptionBudget{}
+	if err := r.Get(ctx, key, pdb); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	r.Log.Info("pod disruption budget resource need to be deleted", "name", key.Name, "namespace", key.Namespace)
+	return client.IgnoreNotFound(r.Delete(ctx, pdb, &deleteOptions))
+}
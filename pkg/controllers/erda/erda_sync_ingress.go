@@ -17,8 +17,7 @@ package erda
 import (
 	"context"
 
-	networkingv1 "k8s.io/api/networking/v1"
-	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -30,30 +29,24 @@ import (
 
 func (r *ErdaReconciler) CreateOrUpdateIngress(ctx context.Context,
 	component *erdav1beta1.Component, owners []metav1.OwnerReference) error {
-	var ingress client.Object
-	var newIngress client.Object
-
-	newIngress = helper.ComposeIngressV1(component, owners)
-	ingress = &networkingv1.Ingress{}
-
-	err := r.Get(ctx, client.ObjectKey{
-		Name:      component.Name,
-		Namespace: component.Namespace,
-	}, ingress)
-	if err != nil {
-		if !k8sErrors.IsNotFound(err) {
-			return err
-		}
-		return r.Create(ctx, newIngress)
+	// the ingress is still applied without the invalid annotations
+	if _, err := helper.ParseIngressAnnotations(component); err != nil {
+		r.Log.Error(err, "parse ingress annotations error", "name", component.Name, "namespace", component.Namespace)
+		r.recordEvent(composeEventObject(component.Namespace, owners), corev1.EventTypeWarning,
+			EventReasonInvalidAnnotation, "invalid annotation %s of component %s: %v",
+			erdav1beta1.AnnotationIngressAnnotation, component.Name, err)
 	}
-	updateIngress, err := r.DiffResource(ingress, newIngress)
-	if err != nil {
-		return err
-	}
-	if updateIngress != nil {
-		return r.Update(ctx, updateIngress)
+	return r.createOrUpdateIngress(ctx,
+		helper.ComposeIngress(r.IngressAPIVersion, component, owners, r.IngressClassName))
+}
+
+// createOrUpdateIngress applies the ingress of networking.k8s.io/v1, the ingress of the beta api
+// versions is created or updated since the servers which only serve them may not support the apply
+func (r *ErdaReconciler) createOrUpdateIngress(ctx context.Context, newIngress client.Object) error {
+	if r.IngressAPIVersion != helper.IngressAPIVersionV1 {
+		return r.createOrUpdateResource(ctx, helper.NewIngress(r.IngressAPIVersion), newIngress)
 	}
-	return nil
+	return r.applyResource(ctx, newIngress)
 }
 
 func (r *ErdaReconciler) DeleteIngress(key types.NamespacedName) error {
@@ -61,7 +54,7 @@ func (r *ErdaReconciler) DeleteIngress(key types.NamespacedName) error {
 	deleteOptions.PropagationPolicy = utils.ConvertDeletePropagationToPoint(metav1.DeletePropagationBackground)
 
 	var ingress client.Object
-	ingress = &networkingv1.Ingress{}
+	ingress = helper.NewIngress(r.IngressAPIVersion)
 
 	getIngressErr := r.Get(context.Background(), key, ingress)
 	if getIngressErr != nil {
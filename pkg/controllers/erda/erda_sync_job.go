@@ -18,9 +18,11 @@ import (
 	"context"
 	"strings"
 	"fmt"
+	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
@@ -40,12 +42,17 @@ func (r *ErdaReconciler) ReconcileJob(ctx context.Context, erda *erdav1beta1.Erd
 
 	for _, job := range erda.Spec.Jobs {
 		if _, ok := erdaJobMap[job.Name]; ok {
-			erda.Status.Phase = erdav1beta1.PhaseFailed
+			helper.TransitPhase(erda.Status, erdav1beta1.PhaseFailed,
+				fmt.Sprintf("job name %s is duplicated", job.Name), time.Now())
 			if err := r.Status().Update(ctx, erda); err != nil {
 				return err
 			}
 			return fmt.Errorf("job name is duplicated, job: %s", job.Name)
 		}
+		// the pre-delete jobs are run in the teardown
+		if helper.IsPreDeleteJob(&job) {
+			continue
+		}
 		erdaJobMap[job.Name] = &job
 	}
 
@@ -54,12 +61,12 @@ func (r *ErdaReconciler) ReconcileJob(ctx context.Context, erda *erdav1beta1.Erd
 	// init job status
 	if erda.Status.Jobs == nil {
 		// reset all status, wait deploying
-		for _, job := range erda.Spec.Jobs {
-			erdaJobStatusMap[job.Name] = erdav1beta1.StatusUnKnown
+		for name := range erdaJobMap {
+			erdaJobStatusMap[name] = erdav1beta1.StatusUnKnown
 		}
 
 		erda.Status.Jobs = erdaJobStatusMap
-		erda.Status.Phase = erdav1beta1.PhaseInitialization
+		helper.TransitPhase(erda.Status, erdav1beta1.PhaseInitialization, "", time.Now())
 		if err := r.Status().Update(ctx, erda); err != nil {
 			return err
 		}
@@ -84,7 +91,7 @@ func (r *ErdaReconciler) ReconcileJob(ctx context.Context, erda *erdav1beta1.Erd
 	if isCompleted {
 		// if all pre jobs completed, start to deploy applications
 		if erda.Status.Phase == erdav1beta1.PhaseInitialization {
-			erda.Status.Phase = erdav1beta1.PhaseDeploying
+			helper.TransitPhase(erda.Status, erdav1beta1.PhaseDeploying, "", time.Now())
 			err := r.Status().Update(ctx, erda)
 			if err != nil {
 				return err
@@ -120,11 +127,19 @@ func (r *ErdaReconciler) ReconcileJob(ctx context.Context, erda *erdav1beta1.Erd
 		}
 		switch jobCondition.Type {
 		case batchv1.JobComplete:
+			if erdaJobStatusMap[erdaJobName] != erdav1beta1.StatusCompleted {
+				r.recordEvent(erda, corev1.EventTypeNormal, EventReasonJobCompleted, "job %s is completed", erdaJobName)
+			}
 			erdaJobStatusMap[erdaJobName] = erdav1beta1.StatusCompleted
 		case batchv1.JobFailed:
+			if erdaJobStatusMap[erdaJobName] != erdav1beta1.StatusFailed {
+				r.recordEvent(erda, corev1.EventTypeWarning, EventReasonJobFailed, "job %s failed: %s",
+					erdaJobName, jobCondition.Message)
+			}
 			erdaJobStatusMap[erdaJobName] = erdav1beta1.StatusFailed
 			erda.Status.Jobs = erdaJobStatusMap
-			erda.Status.Phase = erdav1beta1.PhaseFailed
+			helper.TransitPhase(erda.Status, erdav1beta1.PhaseFailed,
+				fmt.Sprintf("job %s failed", erdaJobName), time.Now())
 			if err := r.Status().Update(ctx, erda); err != nil {
 				return err
 			}
@@ -139,10 +154,11 @@ func (r *ErdaReconciler) ReconcileJob(ctx context.Context, erda *erdav1beta1.Erd
 		if err := r.Client.Create(context.Background(), &kJob); err != nil {
 			return err
 		}
+		r.recordEvent(erda, corev1.EventTypeNormal, EventReasonJobStarted, "job %s is started", eJob.Name)
 		erdaJobStatusMap[eJob.Name] = erdav1beta1.StatusRunning
 	}
 	erda.Status.Jobs = erdaJobStatusMap
-	erda.Status.Phase = erdav1beta1.PhaseInitialization
+	helper.TransitPhase(erda.Status, erdav1beta1.PhaseInitialization, "", time.Now())
 	if err := r.Status().Update(ctx, erda); err != nil {
 		return err
 	}
@@ -0,0 +1,75 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package erda
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+	"github.com/erda-project/erda-operator/pkg/helper"
+	"github.com/erda-project/erda-operator/pkg/utils"
+)
+
+// SyncNetworkPolicies reconciles the network policies of the Erda, the policies which
+// are no longer rendered will be deleted, and all of them are deleted when it is disabled
+func (r *ErdaReconciler) SyncNetworkPolicies(ctx context.Context, erda *erdav1beta1.Erda,
+	references []metav1.OwnerReference) error {
+	var newPolicies []*networkingv1.NetworkPolicy
+	if helper.IsNetworkPolicyEnabled(erda, r.NetworkPolicy) {
+		nodes := &corev1.NodeList{}
+		if err := r.List(ctx, nodes); err != nil {
+			return err
+		}
+		newPolicies = helper.ComposeNetworkPolicies(erda, r.IngressControllerNamespace,
+			helper.ComposeNodeCIDRs(nodes.Items), references)
+	}
+
+	desired := make(map[string]bool, len(newPolicies))
+	for _, newPolicy := range newPolicies {
+		desired[newPolicy.Name] = true
+
+		if err := r.applyResource(ctx, newPolicy); err != nil {
+			return err
+		}
+	}
+
+	policies := &networkingv1.NetworkPolicyList{}
+	err := r.List(ctx, policies, client.InNamespace(erda.Namespace),
+		client.MatchingLabels{
+			erdav1beta1.ErdaOperatorLabel: "true",
+			erdav1beta1.ErdaNameLabel:     erda.Name,
+		})
+	if err != nil {
+		return err
+	}
+	deleteOptions := client.DeleteOptions{}
+	deleteOptions.PropagationPolicy = utils.ConvertDeletePropagationToPoint(metav1.DeletePropagationBackground)
+	for i := range policies.Items {
+		if desired[policies.Items[i].Name] {
+			continue
+		}
+		r.Log.Info("network policy resource need to be deleted", "name", policies.Items[i].Name,
+			"namespace", policies.Items[i].Namespace)
+		if err := r.Delete(ctx, &policies.Items[i], &deleteOptions); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -18,17 +18,23 @@ import (
 	"context"
 	"fmt"
 
-	"k8s.io/apimachinery/pkg/api/errors"
-
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/erda-project/erda-operator/api/v1beta1"
+	"github.com/erda-project/erda-operator/pkg/helper"
 )
 
-func (r *ErdaReconciler) SyncPersistentVolumeClaim(component v1beta1.Component) error {
+// SyncPersistentVolumeClaim applies the claims of the component volumes with storage class, the immutable
+// fields of the existing claims are kept, and the claims are not owned by the Erda to outlive it. The
+// creation and the resize of the claims are recorded as the Events of the Erda
+func (r *ErdaReconciler) SyncPersistentVolumeClaim(erda *v1beta1.Erda, component v1beta1.Component) error {
 	for index, v := range component.Storage.Volumes {
+		// the volume without storage class is mounted from the host path
+		if v.StorageClass == "" {
+			continue
+		}
 		pvc := corev1.PersistentVolumeClaim{}
 		pvcName := fmt.Sprintf("pvc-%s-%d", component.Name, index+1)
 		err := r.Get(context.Background(), types.NamespacedName{
@@ -39,26 +45,21 @@ func (r *ErdaReconciler) SyncPersistentVolumeClaim(component v1beta1.Component)
 			r.Log.Error(err, fmt.Sprintf("get pvc %s error", err))
 			return err
 		}
-		if errors.IsNotFound(err) && v.StorageClass != "" {
-			pvc.Name = pvcName
-			pvc.Namespace = component.Namespace
-			pvc.Spec.StorageClassName = func(s string) *string { return &s }(v.StorageClass)
-			pvc.Spec.Resources = corev1.ResourceRequirements{
-				Limits: corev1.ResourceList{
-					corev1.ResourceStorage: *v.Size,
-				},
-				Requests: corev1.ResourceList{
-					corev1.ResourceStorage: *v.Size,
-				},
-			}
-			createErr := r.Client.Create(context.Background(), &pvc)
-			if createErr != nil {
-				r.Log.Error(createErr, fmt.Sprintf("create pvc %s in %s error", pvc.Name, pvc.Namespace))
-				return createErr
-			}
+
+		newPVC := &corev1.PersistentVolumeClaim{}
+		newPVC.Name = pvcName
+		newPVC.Namespace = component.Namespace
+		newPVC.Labels = helper.ComposeDeclaredLabels(erda.Name)
+		newPVC.Spec.StorageClassName = func(s string) *string { return &s }(v.StorageClass)
+		newPVC.Spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+		// the storage class and the access modes are immutable, the ones of the existing claim are kept
+		if err == nil {
+			newPVC.Spec.StorageClassName = pvc.Spec.StorageClassName
+			newPVC.Spec.AccessModes = pvc.Spec.AccessModes
+			newPVC.Spec.Resources = pvc.Spec.Resources
 		}
-		if v.Size != nil && !pvc.Spec.Resources.Limits.Storage().Equal(*v.Size) {
-			pvc.Spec.Resources = corev1.ResourceRequirements{
+		if v.Size != nil {
+			newPVC.Spec.Resources = corev1.ResourceRequirements{
 				Limits: corev1.ResourceList{
 					corev1.ResourceStorage: *v.Size,
 				},
@@ -66,11 +67,20 @@ func (r *ErdaReconciler) SyncPersistentVolumeClaim(component v1beta1.Component)
 					corev1.ResourceStorage: *v.Size,
 				},
 			}
-			updateErr := r.Client.Update(context.Background(), &pvc)
-			if updateErr != nil {
-				r.Log.Error(updateErr, fmt.Sprintf("update pvc %s in %s error", pvc.Name, pvc.Namespace))
-				return updateErr
-			}
+		}
+		if applyErr := r.applyResource(context.Background(), newPVC); applyErr != nil {
+			r.Log.Error(applyErr, fmt.Sprintf("apply pvc %s in %s error", newPVC.Name, newPVC.Namespace))
+			return applyErr
+		}
+		if err != nil {
+			r.recordEvent(erda, corev1.EventTypeNormal, EventReasonCreated,
+				"persistent volume claim %s is created", newPVC.Name)
+			continue
+		}
+		size, newSize := pvc.Spec.Resources.Requests[corev1.ResourceStorage], newPVC.Spec.Resources.Requests[corev1.ResourceStorage]
+		if size.Cmp(newSize) != 0 {
+			r.recordEvent(erda, corev1.EventTypeNormal, EventReasonResized,
+				"persistent volume claim %s is resized from %s to %s", newPVC.Name, size.String(), newSize.String())
 		}
 	}
 	return nil
@@ -0,0 +1,37 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package erda
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+	"github.com/erda-project/erda-operator/pkg/helper"
+)
+
+// SyncServiceRegistry keeps the service registry ConfigMap of the Erda up to date,
+// it is updated only when the endpoints or the readiness of components change
+func (r *ErdaReconciler) SyncServiceRegistry(ctx context.Context, erda *erdav1beta1.Erda,
+	references []metav1.OwnerReference) error {
+	newConfigMap, err := helper.ComposeServiceRegistryConfigMap(erda, r.ClusterDomain, references)
+	if err != nil {
+		return err
+	}
+
+	// the apply is a no-op when the endpoints and the readiness of components are not changed
+	return r.applyResource(ctx, newConfigMap)
+}
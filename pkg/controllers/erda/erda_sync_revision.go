@@ -0,0 +1,186 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package erda
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+	"github.com/erda-project/erda-operator/pkg/helper"
+)
+
+// listRevisions returns the spec revisions of the Erda sorted by the revision number
+func (r *ErdaReconciler) listRevisions(ctx context.Context, erda *erdav1beta1.Erda) ([]appsv1.ControllerRevision, error) {
+	revisions := &appsv1.ControllerRevisionList{}
+	if err := r.List(ctx, revisions, client.InNamespace(erda.Namespace), client.MatchingLabels{
+		erdav1beta1.ErdaOperatorLabel: "true",
+		erdav1beta1.ErdaNameLabel:     erda.Name,
+	}); err != nil {
+		return nil, err
+	}
+	sort.Slice(revisions.Items, func(i, j int) bool {
+		return revisions.Items[i].Revision < revisions.Items[j].Revision
+	})
+	return revisions.Items, nil
+}
+
+// SyncRevision stores the spec of the Erda as a ControllerRevision, the spec which is applied again
+// takes the next revision number, and the oldest revisions beyond the history limit are deleted
+func (r *ErdaReconciler) SyncRevision(ctx context.Context, erda *erdav1beta1.Erda,
+	references []metav1.OwnerReference) error {
+	revisions, err := r.listRevisions(ctx, erda)
+	if err != nil {
+		return err
+	}
+	next := int64(1)
+	if len(revisions) > 0 {
+		next = revisions[len(revisions)-1].Revision + 1
+	}
+	newRevision, err := helper.ComposeControllerRevision(erda, references, next, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var current *appsv1.ControllerRevision
+	for i := range revisions {
+		if revisions[i].Name == newRevision.Name {
+			current = &revisions[i]
+		}
+	}
+	switch {
+	case current == nil:
+		r.Log.Info("erda revision need to be created", "name", erda.Name, "namespace", erda.Namespace,
+			"revision", next)
+		if err := r.Create(ctx, newRevision); err != nil {
+			return err
+		}
+		current = newRevision
+		revisions = append(revisions, *newRevision)
+	case current.Revision != next-1:
+		// the outcome of the previous time is cleared since the spec is applied again
+		current.Revision = next
+		if current.Annotations == nil {
+			current.Annotations = map[string]string{}
+		}
+		delete(current.Annotations, erdav1beta1.AnnotationRevisionOutcome)
+		current.Annotations[erdav1beta1.AnnotationRevisionAppliedTime] =
+			newRevision.Annotations[erdav1beta1.AnnotationRevisionAppliedTime]
+		if err := r.Update(ctx, current); err != nil {
+			return err
+		}
+		sort.Slice(revisions, func(i, j int) bool {
+			return revisions[i].Revision < revisions[j].Revision
+		})
+	}
+
+	if erda.Status == nil {
+		erda.Status = &erdav1beta1.ErdaStatus{}
+	}
+	erda.Status.Revision = current.Revision
+
+	for i := 0; i < len(revisions)-helper.ComposeRevisionHistoryLimit(erda); i++ {
+		if revisions[i].Name == current.Name {
+			continue
+		}
+		r.Log.Info("erda revision need to be deleted", "name", revisions[i].Name, "namespace", erda.Namespace)
+		if err := r.Delete(ctx, &revisions[i]); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SyncRevisionOutcome records the outcome of the current revision once its rollout finishes, the
+// recorded outcome is kept until the spec of the revision is applied again
+func (r *ErdaReconciler) SyncRevisionOutcome(ctx context.Context, erda *erdav1beta1.Erda, outcome string) error {
+	if outcome == "" {
+		return nil
+	}
+	revision := &appsv1.ControllerRevision{}
+	key := client.ObjectKey{
+		Name:      helper.ComposeRevisionName(erda.Name, helper.ComposeHash(erda.Spec)),
+		Namespace: erda.Namespace,
+	}
+	if err := r.Get(ctx, key, revision); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if revision.Annotations[erdav1beta1.AnnotationRevisionOutcome] != "" {
+		return nil
+	}
+	if revision.Annotations == nil {
+		revision.Annotations = map[string]string{}
+	}
+	revision.Annotations[erdav1beta1.AnnotationRevisionOutcome] = outcome
+	return r.Update(ctx, revision)
+}
+
+// RollbackErda restores the spec of the revision in the rollback annotation, the annotation
+// is removed whether the revision is found or not, the invalid or unknown revision is reported
+// by a Warning event and the status reason instead of being retried
+func (r *ErdaReconciler) RollbackErda(ctx context.Context, erda *erdav1beta1.Erda) error {
+	value := erda.Annotations[erdav1beta1.AnnotationRollbackTo]
+	delete(erda.Annotations, erdav1beta1.AnnotationRollbackTo)
+
+	var rollbackErr error
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		rollbackErr = errors.Errorf("invalid rollback revision %s", value)
+	} else {
+		rollbackErr = errors.Errorf("revision %d is not found", number)
+		revisions, err := r.listRevisions(ctx, erda)
+		if err != nil {
+			return err
+		}
+		for i := range revisions {
+			if revisions[i].Revision != number {
+				continue
+			}
+			spec, err := helper.ComposeErdaSpecFromRevision(&revisions[i])
+			if err != nil {
+				return err
+			}
+			r.Log.Info("erda need to be rolled back", "name", erda.Name, "namespace", erda.Namespace,
+				"revision", number)
+			r.recordEvent(erda, corev1.EventTypeNormal, EventReasonRolledBack, "rolled back to revision %d", number)
+			erda.Spec = spec
+			rollbackErr = nil
+			break
+		}
+	}
+
+	if err := r.Update(ctx, erda); err != nil {
+		return err
+	}
+	if rollbackErr == nil {
+		return nil
+	}
+	r.Log.Info("erda rollback is rejected", "name", erda.Name, "namespace", erda.Namespace, "reason", rollbackErr)
+	r.recordEvent(erda, corev1.EventTypeWarning, EventReasonRollbackFailed, "rollback is rejected: %v", rollbackErr)
+	if erda.Status == nil {
+		erda.Status = &erdav1beta1.ErdaStatus{}
+	}
+	helper.TransitPhase(erda.Status, erda.Status.Phase, fmt.Sprintf("rollback is rejected: %v", rollbackErr), time.Now())
+	return r.Status().Update(ctx, erda)
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package erda
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+	"github.com/erda-project/erda-operator/pkg/helper"
+	"github.com/erda-project/erda-operator/pkg/utils"
+)
+
+// CreateOrUpdateRoutes reconciles the gateway routes of the component,
+// the routes which are no longer rendered will be deleted
+func (r *ErdaReconciler) CreateOrUpdateRoutes(ctx context.Context,
+	component *erdav1beta1.Component, owners []metav1.OwnerReference) error {
+	gateway := helper.ComposeGatewayRef(component, helper.ParseGatewayRef(r.Gateway))
+	if gateway.Name == "" {
+		return errors.Errorf("parent gateway of component %s is not specified", component.Name)
+	}
+	newRoutes := helper.ComposeGatewayRoutes(component, owners, gateway)
+
+	desired := make(map[string]bool, len(newRoutes))
+	for _, newRoute := range newRoutes {
+		desired[newRoute.GetKind()+"/"+newRoute.GetName()] = true
+		if err := r.applyResource(ctx, newRoute); err != nil {
+			return err
+		}
+	}
+
+	return r.deleteRoutes(ctx, component.Namespace, component.Name, desired)
+}
+
+// DeleteRoutes deletes all gateway routes of the component
+func (r *ErdaReconciler) DeleteRoutes(ctx context.Context, key client.ObjectKey) error {
+	return r.deleteRoutes(ctx, key.Namespace, key.Name, nil)
+}
+
+func (r *ErdaReconciler) deleteRoutes(ctx context.Context, namespace, componentName string, reserved map[string]bool) error {
+	deleteOptions := client.DeleteOptions{}
+	deleteOptions.PropagationPolicy = utils.ConvertDeletePropagationToPoint(metav1.DeletePropagationBackground)
+
+	for _, gvk := range []schema.GroupVersionKind{helper.HTTPRouteGVK, helper.GRPCRouteGVK} {
+		routes := &unstructured.UnstructuredList{}
+		routes.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		err := r.List(ctx, routes, client.InNamespace(namespace),
+			client.MatchingLabels{
+				erdav1beta1.ErdaOperatorLabel:  "true",
+				erdav1beta1.ErdaComponentLabel: componentName,
+			})
+		if err != nil {
+			// the Gateway API is not installed, there is nothing to delete
+			if meta.IsNoMatchError(err) {
+				continue
+			}
+			return err
+		}
+		for i := range routes.Items {
+			if reserved[gvk.Kind+"/"+routes.Items[i].GetName()] {
+				continue
+			}
+			r.Log.Info("route resource need to be deleted", "kind", gvk.Kind,
+				"name", routes.Items[i].GetName(), "namespace", namespace)
+			if err := r.Delete(ctx, &routes.Items[i], &deleteOptions); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
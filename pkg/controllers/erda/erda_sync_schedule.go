@@ -0,0 +1,79 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package erda
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+	"github.com/erda-project/erda-operator/pkg/helper"
+)
+
+// SuspendJobs suspends or resumes the unfinished jobs of the Erda, the suspend field
+// is patched since it is not served by the vendored job api, and it is dropped
+// by the servers before Kubernetes 1.21
+func (r *ErdaReconciler) SuspendJobs(ctx context.Context, erda *erdav1beta1.Erda, suspend bool) error {
+	patch := client.RawPatch(types.MergePatchType, []byte(fmt.Sprintf(`{"spec":{"suspend":%t}}`, suspend)))
+	for i := range erda.Spec.Jobs {
+		job := &batchv1.Job{}
+		key := types.NamespacedName{
+			Name:      helper.ComposeKubernetesJobName(erda.Name, &erda.Spec.Jobs[i]),
+			Namespace: erda.Namespace,
+		}
+		if err := r.Get(ctx, key, job); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return err
+			}
+			continue
+		}
+		if finished, _ := helper.IsJobFinished(*job); finished {
+			continue
+		}
+		r.Log.Info("job need to be suspended or resumed", "name", key.Name, "namespace", key.Namespace,
+			"suspend", suspend)
+		if err := r.Patch(ctx, job, patch); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// composeScheduleResult requeues the Erda at the next schedule transition,
+// the earlier requeue of the result is kept
+func composeScheduleResult(result ctrl.Result, state helper.ScheduleState) ctrl.Result {
+	return composeRequeueResult(result, state.NextTransition)
+}
+
+// composeRequeueResult requeues the Erda at the time, the earlier requeue of the result is kept
+func composeRequeueResult(result ctrl.Result, requeueTime time.Time) ctrl.Result {
+	if requeueTime.IsZero() || (result.Requeue && result.RequeueAfter == 0) {
+		return result
+	}
+	requeueAfter := time.Until(requeueTime)
+	if requeueAfter < time.Second {
+		requeueAfter = time.Second
+	}
+	if result.RequeueAfter == 0 || requeueAfter < result.RequeueAfter {
+		result.RequeueAfter = requeueAfter
+	}
+	return result
+}
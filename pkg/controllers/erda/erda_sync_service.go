@@ -18,7 +18,6 @@ import (
 	"context"
 
 	corev1 "k8s.io/api/core/v1"
-	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -30,24 +29,26 @@ import (
 
 func (r *ErdaReconciler) CreateOrUpdateKubernetesService(ctx context.Context,
 	component *erdav1beta1.Component, owners []metav1.OwnerReference) error {
+	return r.applyResource(ctx, helper.ComposeKubernetesService(component, owners))
+}
 
-	newK8sService := helper.ComposeKubernetesService(component, owners)
-	k8sService := &corev1.Service{}
-	err := r.Get(ctx, types.NamespacedName{Namespace: component.Namespace, Name: component.Name}, k8sService)
-	if err != nil {
-		if !k8sErrors.IsNotFound(err) {
-			return err
-		}
-		return r.Create(ctx, newK8sService)
-	}
-	updateK8sService, err := r.DiffResource(k8sService, newK8sService)
-	if err != nil {
-		return err
-	}
-	if updateK8sService != nil {
-		return r.Update(ctx, updateK8sService)
+// CreateOrUpdateHeadlessService reconciles the headless service which governs the StatefulSet
+// of the Stateful component, it is deleted when the component is no longer Stateful
+func (r *ErdaReconciler) CreateOrUpdateHeadlessService(ctx context.Context,
+	component *erdav1beta1.Component, owners []metav1.OwnerReference) error {
+	newK8sService := helper.ComposeHeadlessService(component, owners)
+	if newK8sService == nil {
+		return r.DeleteHeadlessService(types.NamespacedName{Namespace: component.Namespace, Name: component.Name})
 	}
-	return nil
+	return r.applyResource(ctx, newK8sService)
+}
+
+// DeleteHeadlessService deletes the headless service of the component
+func (r *ErdaReconciler) DeleteHeadlessService(key types.NamespacedName) error {
+	return r.DeleteKubernetesService(types.NamespacedName{
+		Namespace: key.Namespace,
+		Name:      helper.ComposeHeadlessServiceName(key.Name),
+	})
 }
 
 func (r *ErdaReconciler) DeleteKubernetesService(key types.NamespacedName) error {
@@ -62,3 +63,84 @@ func (r *ErdaReconciler) DeleteKubernetesService(key types.NamespacedName) error
 	delServiceErr := r.Delete(context.Background(), &k8sService, &deleteOptions)
 	return client.IgnoreNotFound(delServiceErr)
 }
+
+// CreateOrUpdateExternalServices reconciles the NodePort and LoadBalancer services
+// of the component, the external services which are no longer exposed will be deleted
+func (r *ErdaReconciler) CreateOrUpdateExternalServices(ctx context.Context,
+	component *erdav1beta1.Component, owners []metav1.OwnerReference) error {
+	desired := make(map[string]bool)
+	for _, newK8sService := range helper.ComposeExternalServices(component, owners) {
+		desired[newK8sService.Name] = true
+		// the node ports which are not pinned are allocated by Kubernetes and not owned by the apply
+		if err := r.applyResource(ctx, newK8sService); err != nil {
+			return err
+		}
+	}
+
+	for _, exposeType := range []erdav1beta1.ExposeType{erdav1beta1.ExposeNodePort, erdav1beta1.ExposeLoadBalancer} {
+		name := helper.ComposeExternalServiceName(component.Name, exposeType)
+		if desired[name] {
+			continue
+		}
+		if err := r.DeleteKubernetesService(types.NamespacedName{Namespace: component.Namespace, Name: name}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteExternalServices deletes the NodePort and LoadBalancer services of the component
+func (r *ErdaReconciler) DeleteExternalServices(key types.NamespacedName) error {
+	for _, exposeType := range []erdav1beta1.ExposeType{erdav1beta1.ExposeNodePort, erdav1beta1.ExposeLoadBalancer} {
+		err := r.DeleteKubernetesService(types.NamespacedName{
+			Namespace: key.Namespace,
+			Name:      helper.ComposeExternalServiceName(key.Name, exposeType),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateOrUpdateOrdinalServices reconciles the per-ordinal services of the Stateful component,
+// the services of the ordinals which are scaled down will be deleted
+func (r *ErdaReconciler) CreateOrUpdateOrdinalServices(ctx context.Context,
+	component *erdav1beta1.Component, owners []metav1.OwnerReference) error {
+	desired := make(map[string]bool)
+	for _, newK8sService := range helper.ComposeOrdinalServices(component, owners) {
+		desired[newK8sService.Name] = true
+		if err := r.applyResource(ctx, newK8sService); err != nil {
+			return err
+		}
+	}
+	return r.deleteOrdinalServices(ctx, types.NamespacedName{Namespace: component.Namespace, Name: component.Name}, desired)
+}
+
+// DeleteOrdinalServices deletes all per-ordinal services of the component
+func (r *ErdaReconciler) DeleteOrdinalServices(ctx context.Context, key types.NamespacedName) error {
+	return r.deleteOrdinalServices(ctx, key, nil)
+}
+
+func (r *ErdaReconciler) deleteOrdinalServices(ctx context.Context, key types.NamespacedName, reserved map[string]bool) error {
+	k8sServices := corev1.ServiceList{}
+	if err := r.List(ctx, &k8sServices, client.InNamespace(key.Namespace),
+		client.MatchingLabels{
+			erdav1beta1.ErdaOperatorLabel:  "true",
+			erdav1beta1.ErdaComponentLabel: key.Name,
+		}, client.HasLabels{erdav1beta1.ErdaOrdinalLabel}); err != nil {
+		return err
+	}
+	for i := range k8sServices.Items {
+		if reserved[k8sServices.Items[i].Name] {
+			continue
+		}
+		if err := r.DeleteKubernetesService(types.NamespacedName{
+			Namespace: key.Namespace,
+			Name:      k8sServices.Items[i].Name,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package erda
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+	"github.com/erda-project/erda-operator/pkg/helper"
+)
+
+// SyncIngressNginxStreams registers the exposed ports of the component in the
+// ingress-nginx tcp-services and udp-services ConfigMaps. The entries of other
+// services are kept, and the update is rejected with conflict if the ConfigMap
+// is changed concurrently, so multiple Erdas can share the ConfigMaps safely.
+func (r *ErdaReconciler) SyncIngressNginxStreams(ctx context.Context, component *erdav1beta1.Component) error {
+	streams := helper.ComposeIngressNginxStreams(component)
+	for protocol, configMapName := range r.ingressNginxStreamConfigMaps() {
+		if err := r.syncIngressNginxStream(ctx, configMapName, component.Namespace, component.Name,
+			streams[protocol]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteIngressNginxStreams removes all entries of the component from the ingress-nginx stream ConfigMaps
+func (r *ErdaReconciler) DeleteIngressNginxStreams(ctx context.Context, key types.NamespacedName) error {
+	for _, configMapName := range r.ingressNginxStreamConfigMaps() {
+		if err := r.syncIngressNginxStream(ctx, configMapName, key.Namespace, key.Name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ErdaReconciler) ingressNginxStreamConfigMaps() map[corev1.Protocol]string {
+	configMaps := make(map[corev1.Protocol]string)
+	if r.TCPServicesConfigMap != "" {
+		configMaps[corev1.ProtocolTCP] = r.TCPServicesConfigMap
+	}
+	if r.UDPServicesConfigMap != "" {
+		configMaps[corev1.ProtocolUDP] = r.UDPServicesConfigMap
+	}
+	return configMaps
+}
+
+func (r *ErdaReconciler) syncIngressNginxStream(ctx context.Context, configMapName, namespace, name string,
+	entries map[string]string) error {
+	key := parseNamespacedName(configMapName)
+	configMap := &corev1.ConfigMap{}
+	err := r.Get(ctx, key, configMap)
+	if err != nil {
+		if !k8sErrors.IsNotFound(err) {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		configMap.Name = key.Name
+		configMap.Namespace = key.Namespace
+		configMap.Data = entries
+		return r.Create(ctx, configMap)
+	}
+
+	changed := false
+	if configMap.Data == nil {
+		configMap.Data = make(map[string]string)
+	}
+	for port, target := range configMap.Data {
+		if _, ok := entries[port]; ok || !helper.IsIngressNginxStreamOf(target, namespace, name) {
+			continue
+		}
+		delete(configMap.Data, port)
+		changed = true
+	}
+	for port, target := range entries {
+		existed, ok := configMap.Data[port]
+		if ok && existed == target {
+			continue
+		}
+		if ok && !helper.IsIngressNginxStreamOf(existed, namespace, name) {
+			return fmt.Errorf("port %s of %s is already used by %s", port, configMapName, existed)
+		}
+		configMap.Data[port] = target
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	r.Log.Info("update ingress-nginx stream configmap", "name", configMapName, "component", name)
+	return r.Update(ctx, configMap)
+}
+
+// parseNamespacedName parses the string in format namespace/name
+func parseNamespacedName(s string) types.NamespacedName {
+	if i := strings.Index(s, "/"); i >= 0 {
+		return types.NamespacedName{Namespace: s[:i], Name: s[i+1:]}
+	}
+	return types.NamespacedName{Name: s}
+}
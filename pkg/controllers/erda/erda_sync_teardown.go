@@ -0,0 +1,284 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package erda
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+	"github.com/erda-project/erda-operator/pkg/helper"
+	"github.com/erda-project/erda-operator/pkg/utils"
+)
+
+// teardownRequeuePeriod is the period to check the progress of the teardown, the pre-delete jobs
+// and the workloads being deleted are not observed by the watches
+const teardownRequeuePeriod = 10 * time.Second
+
+// TeardownErda tears down the resources of the Erda in the reverse order of the deployment, the
+// pre-delete jobs are run first, then the components of the applications are deleted from the last
+// one, and the claims and the configurations which are not retained are deleted at last. The progress
+// is reported in the status, and the finalizer is removed when it returns true
+func (r *ErdaReconciler) TeardownErda(ctx context.Context, erda *erdav1beta1.Erda) (bool, error) {
+	if erda.Status == nil {
+		erda.Status = &erdav1beta1.ErdaStatus{}
+	}
+
+	if erda.Spec != nil {
+		for _, step := range []func(context.Context, *erdav1beta1.Erda) (string, error){
+			r.runPreDeleteJobs,
+			r.deleteApplications,
+			r.deleteDeclaredResources,
+		} {
+			progress, err := step(ctx, erda)
+			if err != nil {
+				return false, err
+			}
+			if progress != "" {
+				return false, r.updateDeletingStatus(ctx, erda, progress)
+			}
+		}
+	}
+
+	if err := r.updateDeletingStatus(ctx, erda, "the resources are torn down"); err != nil {
+		return false, err
+	}
+	r.Log.Info("erda finalizer need to be removed", "name", erda.Name, "namespace", erda.Namespace)
+	controllerutil.RemoveFinalizer(erda, erdav1beta1.ErdaFinalizer)
+	return true, r.Update(ctx, erda)
+}
+
+// updateDeletingStatus reports the progress of the teardown in the reason of the Deleting phase
+func (r *ErdaReconciler) updateDeletingStatus(ctx context.Context, erda *erdav1beta1.Erda, progress string) error {
+	if erda.Status.Phase == erdav1beta1.PhaseDeleting && erda.Status.Reason == progress {
+		return nil
+	}
+	helper.TransitPhase(erda.Status, erdav1beta1.PhaseDeleting, progress, time.Now())
+	return r.Status().Update(ctx, erda)
+}
+
+// runPreDeleteJobs runs the pre-delete jobs one by one, the teardown is blocked by the failed job
+// until it is deleted to run again, or the jobs which are not completed are skipped by the annotation.
+// The jobs are not owned by the Erda to survive the cascading deletion, their outcomes are recorded in
+// the job status since the finished jobs are removed after the TTL, and they are deleted after all
+// of them are completed or skipped
+func (r *ErdaReconciler) runPreDeleteJobs(ctx context.Context, erda *erdav1beta1.Erda) (string, error) {
+	if erda.Status.Jobs == nil {
+		erda.Status.Jobs = map[string]erdav1beta1.StatusType{}
+	}
+	skip, _ := strconv.ParseBool(erda.Annotations[erdav1beta1.AnnotationSkipPreDeleteJobs])
+	var completed []*batchv1.Job
+	for i := range erda.Spec.Jobs {
+		eJob := erda.Spec.Jobs[i]
+		if !helper.IsPreDeleteJob(&eJob) {
+			continue
+		}
+		eJob.Namespace = erda.Namespace
+		kJob := &batchv1.Job{}
+		key := types.NamespacedName{Name: helper.ComposeKubernetesJobName(erda.Name, &eJob), Namespace: erda.Namespace}
+		if skip && erda.Status.Jobs[eJob.Name] != erdav1beta1.StatusCompleted {
+			if erda.Status.Jobs[eJob.Name] != erdav1beta1.StatusSkipped {
+				r.recordEvent(erda, corev1.EventTypeWarning, EventReasonJobSkipped,
+					"pre-delete job %s is skipped by the annotation %s", eJob.Name, erdav1beta1.AnnotationSkipPreDeleteJobs)
+			}
+			erda.Status.Jobs[eJob.Name] = erdav1beta1.StatusSkipped
+			kJob.Name, kJob.Namespace = key.Name, key.Namespace
+			completed = append(completed, kJob)
+			continue
+		}
+		if err := r.Get(ctx, key, kJob); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return "", err
+			}
+			if erda.Status.Jobs[eJob.Name] == erdav1beta1.StatusCompleted {
+				continue
+			}
+			newJob := helper.ComposeKubernetesJob(erda.Name, &eJob, nil)
+			r.Log.Info("pre-delete job need to be created", "name", key.Name, "namespace", key.Namespace)
+			if err := r.Create(ctx, &newJob); err != nil {
+				return "", err
+			}
+			r.recordEvent(erda, corev1.EventTypeNormal, EventReasonJobStarted, "pre-delete job %s is started", eJob.Name)
+			erda.Status.Jobs[eJob.Name] = erdav1beta1.StatusRunning
+			return fmt.Sprintf("running pre-delete job %s", eJob.Name), nil
+		}
+
+		finished, condition := helper.IsJobFinished(*kJob)
+		switch {
+		case !finished:
+			erda.Status.Jobs[eJob.Name] = erdav1beta1.StatusRunning
+			return fmt.Sprintf("running pre-delete job %s", eJob.Name), nil
+		case condition.Type == batchv1.JobFailed:
+			if erda.Status.Jobs[eJob.Name] != erdav1beta1.StatusFailed {
+				r.recordEvent(erda, corev1.EventTypeWarning, EventReasonJobFailed, "pre-delete job %s failed: %s",
+					eJob.Name, condition.Message)
+			}
+			erda.Status.Jobs[eJob.Name] = erdav1beta1.StatusFailed
+			return fmt.Sprintf("pre-delete job %s failed: %s, set the annotation %s to skip it", eJob.Name,
+				condition.Message, erdav1beta1.AnnotationSkipPreDeleteJobs), nil
+		}
+		if erda.Status.Jobs[eJob.Name] != erdav1beta1.StatusCompleted {
+			r.recordEvent(erda, corev1.EventTypeNormal, EventReasonJobCompleted, "pre-delete job %s is completed", eJob.Name)
+		}
+		erda.Status.Jobs[eJob.Name] = erdav1beta1.StatusCompleted
+		completed = append(completed, kJob)
+	}
+
+	deleteOptions := client.DeleteOptions{}
+	deleteOptions.PropagationPolicy = utils.ConvertDeletePropagationToPoint(metav1.DeletePropagationBackground)
+	for _, kJob := range completed {
+		if err := r.Delete(ctx, kJob, &deleteOptions); client.IgnoreNotFound(err) != nil {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// deleteApplications deletes the components of the applications in the reverse order, the
+// traffic of the component is cut off before its workloads are deleted, and the previous
+// application is not deleted until all the pods of the later one are terminated
+func (r *ErdaReconciler) deleteApplications(ctx context.Context, erda *erdav1beta1.Erda) (string, error) {
+	for i := len(erda.Spec.Applications) - 1; i >= 0; i-- {
+		app := erda.Spec.Applications[i]
+		remaining := 0
+		for j := len(app.Components) - 1; j >= 0; j-- {
+			key := types.NamespacedName{Name: app.Components[j].Name, Namespace: erda.Namespace}
+			if err := r.deleteComponentResources(key); err != nil {
+				return "", err
+			}
+			count, err := r.deleteComponentWorkloads(ctx, erda, key)
+			if err != nil {
+				return "", err
+			}
+			remaining += count
+		}
+		if remaining > 0 {
+			return fmt.Sprintf("deleting %d workloads of application %s", remaining, app.Name), nil
+		}
+	}
+	return "", nil
+}
+
+// deleteComponentWorkloads deletes the workloads of the component in the foreground, so they are
+// kept until their pods are terminated, and it returns the count of the workloads which remain,
+// the canary Deployment labeled as <component>-canary is counted as the workload of the component
+func (r *ErdaReconciler) deleteComponentWorkloads(ctx context.Context, erda *erdav1beta1.Erda,
+	key types.NamespacedName) (int, error) {
+	deleteOptions := client.DeleteOptions{}
+	deleteOptions.PropagationPolicy = utils.ConvertDeletePropagationToPoint(metav1.DeletePropagationForeground)
+
+	selector, err := labels.ValidatedSelectorFromSet(labels.Set{erdav1beta1.ErdaOperatorLabel: "true"})
+	if err != nil {
+		return 0, err
+	}
+	requirement, err := labels.NewRequirement(erdav1beta1.ErdaComponentLabel, selection.In,
+		[]string{key.Name, helper.ComposeCanaryName(key.Name)})
+	if err != nil {
+		return 0, err
+	}
+	selector = selector.Add(*requirement)
+
+	remaining := 0
+	for _, objList := range []client.ObjectList{&appsv1.DeploymentList{}, &appsv1.StatefulSetList{},
+		&appsv1.DaemonSetList{}} {
+		if err := r.List(ctx, objList, client.InNamespace(key.Namespace),
+			client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return 0, err
+		}
+		var objs []client.Object
+		switch v := objList.(type) {
+		case *appsv1.DeploymentList:
+			for i := range v.Items {
+				objs = append(objs, &v.Items[i])
+			}
+		case *appsv1.StatefulSetList:
+			for i := range v.Items {
+				objs = append(objs, &v.Items[i])
+			}
+		case *appsv1.DaemonSetList:
+			for i := range v.Items {
+				objs = append(objs, &v.Items[i])
+			}
+		}
+
+		for _, obj := range objs {
+			if !helper.IsOwnedByErda(obj, erda) {
+				continue
+			}
+			remaining++
+			if !obj.GetDeletionTimestamp().IsZero() {
+				continue
+			}
+			r.Log.Info("workflow resource need to be deleted", "name", obj.GetName(), "namespace", obj.GetNamespace())
+			if err := r.Delete(ctx, obj, &deleteOptions); err != nil {
+				if client.IgnoreNotFound(err) != nil {
+					return 0, err
+				}
+				continue
+			}
+			r.recordEvent(erda, corev1.EventTypeNormal, EventReasonDeleted, "%s %s is deleted",
+				r.composeKind(obj), obj.GetName())
+		}
+	}
+	return remaining, nil
+}
+
+// deleteDeclaredResources deletes the claims and the configurations which are not owned by the
+// Erda, the retained ones are kept for the next Erda, and the ones which are declared by the other
+// Erdas in the namespace are kept for them
+func (r *ErdaReconciler) deleteDeclaredResources(ctx context.Context, erda *erdav1beta1.Erda) (string, error) {
+	erdaList := &erdav1beta1.ErdaList{}
+	if err := r.List(ctx, erdaList, client.InNamespace(erda.Namespace)); err != nil {
+		return "", err
+	}
+	others := make([]erdav1beta1.Erda, 0, len(erdaList.Items))
+	for _, other := range erdaList.Items {
+		if other.UID != erda.UID && other.Spec != nil {
+			others = append(others, other)
+		}
+	}
+
+	var objs []client.Object
+	for _, name := range helper.ComposeDeletedClaimNames(erda, others) {
+		objs = append(objs, &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	for _, name := range helper.ComposeDeletedConfigurationNames(erda, others, erdav1beta1.ConfigurationConfigMap) {
+		objs = append(objs, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	for _, name := range helper.ComposeDeletedConfigurationNames(erda, others, erdav1beta1.ConfigurationSecret) {
+		objs = append(objs, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+
+	for _, obj := range objs {
+		obj.SetNamespace(erda.Namespace)
+		r.Log.Info("declared resource need to be deleted", "name", obj.GetName(), "namespace", obj.GetNamespace())
+		if err := r.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+			return "", err
+		}
+	}
+	return "", nil
+}
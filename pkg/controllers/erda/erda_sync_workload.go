@@ -16,49 +16,54 @@ package erda
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"reflect"
-
-	"github.com/go-test/deep"
+	"time"
 
 	"github.com/pkg/errors"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	networkingv1 "k8s.io/api/networking/v1"
 	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 
 	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
 	"github.com/erda-project/erda-operator/pkg/helper"
 	"github.com/erda-project/erda-operator/pkg/utils"
 )
 
-func (r *ErdaReconciler) ReconcileApplication(ctx context.Context, erda *erdav1beta1.Erda, references []metav1.OwnerReference) error {
+func (r *ErdaReconciler) ReconcileApplication(ctx context.Context, erda *erdav1beta1.Erda,
+	references []metav1.OwnerReference, state helper.ScheduleState) (time.Time, error) {
 	if erda == nil {
-		return nil
+		return time.Time{}, nil
 	}
-	dependEnvs := utils.ComposeDependEnvs(*erda)
+	dependEnvs := utils.ComposeDependEnvs(*erda, r.ClusterDomain)
+	// the status of the workloads which are changed in this round is not observed yet
+	updated := false
 	for _, app := range erda.Spec.Applications {
 		for _, component := range app.Components {
 			// set component.Namespace value from Erda.Namespace
 			component.Namespace = erda.Namespace
 			component.Labels = utils.MergeMap(app.Labels, component.Labels)
 			component.Annotations = utils.MergeMap(app.Annotations, component.Annotations)
+			helper.ApplyScheduleState(&component, state)
 
-			err := r.SyncPersistentVolumeClaim(component)
+			err := r.SyncPersistentVolumeClaim(erda, component)
 			if client.IgnoreNotFound(err) != nil {
 				r.Log.Error(err, "sync pvc error")
-				return err
+				return time.Time{}, err
 			}
 
-			// filter the existed secrets when be used in components
-			r.SyncConfigurations(&component)
+			// the configurations are applied before the workloads mount them
+			r.SyncConfigurations(erda, &component)
 
 			if len(component.Network.ServiceDiscovery) > 0 {
-				component.Envs = append(component.Envs, utils.ComposeSelfADDREnv(component,
-					utils.ParseProtocol(app.Annotations[erdav1beta1.AnnotationSSLEnabled]))...)
+				format := utils.ComposeServiceAddressFormat(app.Annotations, component.Annotations)
+				component.Envs = append(component.Envs, utils.ComposeSelfADDREnv(component, format,
+					utils.ParseProtocol(app.Annotations[erdav1beta1.AnnotationSSLEnabled]), r.ClusterDomain)...)
+				component.Envs = append(component.Envs, utils.ComposeOrdinalADDREnvs(component, format, r.ClusterDomain)...)
 			}
 			component.Envs = append(component.Envs, utils.ComposeResourceToEnvs(component)...)
 			component.Envs = utils.MergeEnvs(app.Envs, component.Envs)
@@ -71,20 +76,43 @@ func (r *ErdaReconciler) ReconcileApplication(ctx context.Context, erda *erdav1b
 				component.EnvFrom = app.EnvFrom
 			}
 
-			err, _ = r.ReconcileWorkload(ctx, component, references)
+			err, needUpdateStatus := r.ReconcileWorkload(ctx, component, references)
+			updated = updated || needUpdateStatus
 			if err != nil {
 				r.Log.Error(err, "reconcile workload error", "name", erda.Name, "namespace", erda.Namespace,
 					"component", component.Name)
-				return err
+				return time.Time{}, err
+			}
+
+			// the canary of the rejected component is kept as it is too
+			if helper.ComposeRolloutConflict(&component) != "" {
+				continue
+			}
+			if err := r.ReconcileCanary(ctx, erda, &component, references); err != nil {
+				r.Log.Error(err, "reconcile canary error", "name", erda.Name, "namespace", erda.Namespace,
+					"component", component.Name)
+				return time.Time{}, err
 			}
 		}
 	}
 
-	if err := r.SyncWorkLoadStatus(ctx, erda); err != nil {
-		return err
+	if err := r.SyncNetworkPolicies(ctx, erda, references); err != nil {
+		r.Log.Error(err, "sync network policy error", "name", erda.Name, "namespace", erda.Namespace)
+		return time.Time{}, err
 	}
 
-	return nil
+	requeueTime, err := r.SyncWorkLoadStatus(ctx, erda, state.Hibernated, updated)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// the registry follows the component status which is observed above
+	if err := r.SyncServiceRegistry(ctx, erda, references); err != nil {
+		r.Log.Error(err, "sync service registry error", "name", erda.Name, "namespace", erda.Namespace)
+		return time.Time{}, err
+	}
+
+	return requeueTime, nil
 }
 
 func (r *ErdaReconciler) ReconcileWorkload(ctx context.Context,
@@ -94,13 +122,45 @@ func (r *ErdaReconciler) ReconcileWorkload(ctx context.Context,
 		component.WorkLoad = erdav1beta1.Stateless
 	}
 
-	workLoadErr, needUpdateStatus := r.CreateOrUpdateWorkLoad(ctx, &component, references)
+	// the rejected component is kept as it is, the reason is reported in the component status
+	if conflict := helper.ComposeRolloutConflict(&component); conflict != "" {
+		r.recordEvent(composeEventObject(component.Namespace, references), corev1.EventTypeWarning,
+			EventReasonInvalidRollout, "component %s is rejected: %s", component.Name, conflict)
+		return nil, false
+	}
+
+	var (
+		workLoadErr      error
+		needUpdateStatus bool
+	)
+	if helper.IsBlueGreenEnabled(&component) {
+		var selector map[string]string
+		selector, needUpdateStatus, workLoadErr = r.ReconcileBlueGreen(ctx, &component, references)
+		// the Service and the other resources select the pods of the active color
+		if len(selector) > 0 {
+			component.Labels = utils.AppendLabels(utils.MergeMap(component.Labels, nil), selector)
+		}
+	} else {
+		workLoadErr, needUpdateStatus = r.CreateOrUpdateWorkLoad(ctx, &component, references)
+	}
 	if workLoadErr != nil {
 		r.Log.Error(workLoadErr, "handle workload error", "type", component.WorkLoad,
 			"name", component.Name, "namespace", component.Namespace)
 		return workLoadErr, needUpdateStatus
 	}
 
+	if err := r.CreateOrUpdateHorizontalPodAutoscaler(ctx, &component, references); err != nil {
+		r.Log.Error(err, "handle autoscaler error", "name", component.Name,
+			"namespace", component.Namespace)
+		return err, needUpdateStatus
+	}
+
+	if err := r.CreateOrUpdatePodDisruptionBudget(ctx, &component, references); err != nil {
+		r.Log.Error(err, "handle pod disruption budget error", "name", component.Name,
+			"namespace", component.Namespace)
+		return err, needUpdateStatus
+	}
+
 	if len(component.Network.ServiceDiscovery) > 0 {
 		k8sServiceErr := r.CreateOrUpdateKubernetesService(ctx, &component, references)
 		if k8sServiceErr != nil {
@@ -108,6 +168,30 @@ func (r *ErdaReconciler) ReconcileWorkload(ctx context.Context,
 				"namespace", component.Namespace)
 			return k8sServiceErr, needUpdateStatus
 		}
+	}
+	// the Stateful component always needs the governing service, even if it is not discovered
+	if err := r.CreateOrUpdateHeadlessService(ctx, &component, references); err != nil {
+		r.Log.Error(err, "handle headless service error", "name", component.Name,
+			"namespace", component.Namespace)
+		return err, needUpdateStatus
+	}
+	if err := r.CreateOrUpdateOrdinalServices(ctx, &component, references); err != nil {
+		r.Log.Error(err, "handle ordinal service error", "name", component.Name,
+			"namespace", component.Namespace)
+		return err, needUpdateStatus
+	}
+
+	if len(component.Network.ServiceDiscovery) > 0 {
+		if err := r.CreateOrUpdateExternalServices(ctx, &component, references); err != nil {
+			r.Log.Error(err, "handle external service error", "name", component.Name,
+				"namespace", component.Namespace)
+			return err, needUpdateStatus
+		}
+		if err := r.SyncIngressNginxStreams(ctx, &component); err != nil {
+			r.Log.Error(err, "handle ingress-nginx stream error", "name", component.Name,
+				"namespace", component.Namespace)
+			return err, needUpdateStatus
+		}
 		ingressCount := 0
 		for _, sd := range component.Network.ServiceDiscovery {
 			if sd.Domain != "" {
@@ -115,16 +199,37 @@ func (r *ErdaReconciler) ReconcileWorkload(ctx context.Context,
 			}
 		}
 		if ingressCount > 0 {
-			ingressErr := r.CreateOrUpdateIngress(ctx, &component, references)
-			if ingressErr != nil {
-				r.Log.Error(ingressErr, "handle ingress error")
-				return ingressErr, needUpdateStatus
+			if err := r.ReconcileRouting(ctx, &component, references); err != nil {
+				r.Log.Error(err, "handle routing error", "name", component.Name,
+					"namespace", component.Namespace)
+				return err, needUpdateStatus
 			}
 		}
 	}
 	return nil, needUpdateStatus
 }
 
+// ReconcileRouting exposes the component domains by the routing mode,
+// the resources of the other routing mode will be cleaned up
+func (r *ErdaReconciler) ReconcileRouting(ctx context.Context,
+	component *erdav1beta1.Component, references []metav1.OwnerReference) error {
+	key := types.NamespacedName{Name: component.Name, Namespace: component.Namespace}
+	switch helper.ComposeRoutingMode(component, r.RoutingMode) {
+	case erdav1beta1.RoutingModeGateway:
+		if err := r.CreateOrUpdateRoutes(ctx, component, references); err != nil {
+			return err
+		}
+		return r.DeleteIngress(key)
+	case erdav1beta1.RoutingModeIngress:
+		if err := r.CreateOrUpdateIngress(ctx, component, references); err != nil {
+			return err
+		}
+		return r.DeleteRoutes(ctx, key)
+	default:
+		return errors.Errorf("unsupported routing mode %s", component.Annotations[erdav1beta1.AnnotationRoutingMode])
+	}
+}
+
 func (r *ErdaReconciler) CreateOrUpdateWorkLoad(ctx context.Context,
 	component *erdav1beta1.Component, owners []metav1.OwnerReference) (error, bool) {
 
@@ -148,85 +253,269 @@ func (r *ErdaReconciler) CreateOrUpdateWorkLoad(ctx context.Context,
 	}
 
 	err := r.Get(ctx, types.NamespacedName{Name: component.Name, Namespace: component.Namespace}, obj)
-	if err != nil {
-		if !k8sErrors.IsNotFound(err) {
+	if client.IgnoreNotFound(err) != nil {
+		return err, false
+	}
+
+	if helper.IsAutoscalingEnabled(component) && err == nil {
+		// the replicas updated before the apply is upgraded into the apply to be handed over
+		if err := r.upgradeManagedFields(ctx, obj); err != nil {
 			return err, false
 		}
-		return r.Create(ctx, newObj), true
+		if err := r.handOverReplicas(ctx, obj); err != nil {
+			return err, false
+		}
+		if replicas := helper.OmitWorkloadReplicas(obj, newObj); replicas != nil {
+			r.Log.Info("workload need to be resumed", "name", component.Name, "namespace", component.Namespace,
+				"replicas", *replicas)
+			// the replicas is not owned by the apply, so the autoscaler takes it over after resuming
+			patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+			helper.SetWorkloadReplicas(obj, replicas)
+			if err := r.Patch(ctx, obj, patch, client.FieldOwner(FieldManager)); err != nil {
+				return err, false
+			}
+		}
+	}
+	// the pod management policy and the governing service are immutable, they are only applied when
+	// the StatefulSet is created
+	if statefulSet, ok := obj.(*appsv1.StatefulSet); ok && statefulSet.Spec.PodManagementPolicy != "" {
+		newObj.(*appsv1.StatefulSet).Spec.PodManagementPolicy = statefulSet.Spec.PodManagementPolicy
+		newObj.(*appsv1.StatefulSet).Spec.ServiceName = statefulSet.Spec.ServiceName
 	}
 
-	workload, err := r.DiffResource(obj, newObj)
-	if err != nil {
+	helper.ComposeRolloutAnnotations(obj, newObj, time.Now())
+	kind := r.composeKind(newObj)
+	if k8sErrors.IsNotFound(err) {
+		if err := r.applyResource(ctx, newObj); err != nil {
+			return err, true
+		}
+		r.recordEvent(composeEventObject(component.Namespace, owners), corev1.EventTypeNormal, EventReasonCreated,
+			"%s %s is created", kind, component.Name)
+		return nil, true
+	}
+	if err := r.syncLastKnownGood(ctx, obj, newObj); err != nil {
+		return err, false
+	}
+	if err := r.rollbackWorkLoad(ctx, component, obj, newObj); err != nil {
 		return err, false
 	}
-	if workload != nil {
-		return r.Update(ctx, workload), true
+
+	generation := obj.GetGeneration()
+	if err := r.applyResource(ctx, newObj); err != nil {
+		return err, false
+	}
+	// the status of the changed spec is not observed yet
+	if newObj.GetGeneration() != generation {
+		r.Log.Info("workload is updated", "name", component.Name, "namespace", component.Namespace,
+			"generation", newObj.GetGeneration())
+		r.recordEvent(composeEventObject(component.Namespace, owners), corev1.EventTypeNormal, EventReasonUpdated,
+			"%s %s is updated to generation %d", kind, component.Name, newObj.GetGeneration())
+		return nil, true
 	}
 	return nil, false
 }
 
-func (r *ErdaReconciler) DiffResource(oldObj, newObj client.Object) (client.Object, error) {
-	if reflect.DeepEqual(oldObj.GetObjectKind(), newObj.GetObjectKind()) {
-		return nil, fmt.Errorf("different kind between oldOjb %s and newObj %s", oldObj.GetObjectKind(), newObj.GetObjectKind())
+// rollbackWorkLoad replaces the pod template of the new workload with the last known-good one
+// when the rollout fails, and keeps it until the pod template of the component is changed
+func (r *ErdaReconciler) rollbackWorkLoad(ctx context.Context, component *erdav1beta1.Component,
+	obj, newObj client.Object) error {
+	template := helper.ComposeWorkloadPodTemplate(newObj)
+	if helper.IsRolledBack(obj, newObj) {
+		*template = helper.ComposePodTemplateSpecFromPodTemplate(*helper.ComposeWorkloadPodTemplate(obj))
+		return nil
+	}
+	if !helper.IsAutoRollbackEnabled(component) {
+		return nil
+	}
+	failure := helper.ComposeWorkloadFailure(obj, component, time.Now())
+	if failure == "" {
+		return nil
+	}
+	hash := helper.ComposeLastKnownGoodHash(obj)
+	if hash == "" {
+		return nil
+	}
+	revision := &appsv1.ControllerRevision{}
+	if err := r.Get(ctx, types.NamespacedName{
+		Name:      helper.ComposeLastKnownGoodRevisionName(obj.GetName(), hash),
+		Namespace: obj.GetNamespace(),
+	}, revision); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	lastKnownGood, err := helper.ComposePodTemplateFromRevision(revision)
+	if err != nil {
+		return err
+	}
+
+	r.Log.Info("workload need to be rolled back", "name", component.Name, "namespace", component.Namespace,
+		"reason", failure)
+	r.recordEvent(obj, corev1.EventTypeWarning, EventReasonRolledBack,
+		"rolled back to the last known-good template: %s", failure)
+	*template = *lastKnownGood
+	annotations := newObj.GetAnnotations()
+	annotations[erdav1beta1.AnnotationRollback] = annotations[erdav1beta1.AnnotationTemplateHash]
+	annotations[erdav1beta1.AnnotationRollbackReason] = failure
+	annotations[erdav1beta1.AnnotationRolloutTime] = time.Now().UTC().Format(time.RFC3339)
+	return nil
+}
+
+// syncLastKnownGood keeps the pod template of the rolled out workload in the ControllerRevision which
+// is owned by the workload when its hash is recorded as the last known-good one, the revision of the
+// previous last known-good pod template is deleted
+func (r *ErdaReconciler) syncLastKnownGood(ctx context.Context, obj, newObj client.Object) error {
+	hash, newHash := helper.ComposeLastKnownGoodHash(obj), helper.ComposeLastKnownGoodHash(newObj)
+	if newHash == "" || newHash == hash {
+		return nil
+	}
+	gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+	if err != nil {
+		return err
 	}
-	if oldDeployment, ok := oldObj.(*appsv1.Deployment); ok {
-		newDeployment := newObj.(*appsv1.Deployment)
-		deploySpec := helper.ComposeDeploymentSpecFromK8sDeployment(oldDeployment)
+	template := helper.ComposePodTemplateSpecFromPodTemplate(*helper.ComposeWorkloadPodTemplate(obj))
+	revision, err := helper.ComposeLastKnownGoodRevision(obj, template, []metav1.OwnerReference{
+		{
+			APIVersion: gvk.GroupVersion().String(),
+			Kind:       gvk.Kind,
+			Name:       obj.GetName(),
+			UID:        obj.GetUID(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if err := r.Create(ctx, revision); err != nil && !k8sErrors.IsAlreadyExists(err) {
+		return err
+	}
+	if hash == "" {
+		return nil
+	}
+	return client.IgnoreNotFound(r.Delete(ctx, &appsv1.ControllerRevision{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      helper.ComposeLastKnownGoodRevisionName(obj.GetName(), hash),
+			Namespace: obj.GetNamespace(),
+		},
+	}))
+}
 
-		if equal := deep.Equal(deploySpec, newDeployment.Spec); equal != nil {
-			r.Log.Info(fmt.Sprintf("name: %s diff object is %+v", newDeployment.Name, equal))
-			return newDeployment, nil
-		}
+// FieldManager is the field manager of the resources which are applied by the operator
+const FieldManager = "erda-operator"
+
+// CSAFieldManagers are the field managers of the updates by the operator before the resources are
+// applied, the field manager is derived from the binary name of the operator when it is not set
+var CSAFieldManagers = []string{"manager", FieldManager}
+
+// ReplicasHandoverFieldManager is the field manager which keeps the replicas of the workload for the
+// autoscaler when the operator stops applying it
+const ReplicasHandoverFieldManager = "erda-operator-replicas-handover"
+
+// handOverReplicas shares the ownership of the replicas applied by the operator with the handover
+// field manager when the autoscaling turns on, otherwise the replicas is removed by the apply without
+// it and defaults to 1. The autoscaler takes the ownership over when it scales the workload
+func (r *ErdaReconciler) handOverReplicas(ctx context.Context, obj client.Object) error {
+	if !helper.IsReplicasAppliedBy(obj, FieldManager) {
+		return nil
+	}
+	gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+	if err != nil {
+		return err
+	}
+	handover := helper.ComposeReplicasHandover(obj, gvk)
+	if handover == nil {
+		return nil
 	}
-	if oldDaemonSet, ok := oldObj.(*appsv1.DaemonSet); ok {
-		newDaemonSet := newObj.(*appsv1.DaemonSet)
-		daemonSetSpec := helper.ComposeDaemonSetSpecFromK8sDaemonSet(oldDaemonSet)
-		if equal := deep.Equal(daemonSetSpec, newDaemonSet.Spec); equal != nil {
-			r.Log.Info(fmt.Sprintf("name: %s diff object is %+v", newDaemonSet.Name, equal))
-			return newDaemonSet, nil
+	r.Log.Info("workload replicas need to be handed over", "name", obj.GetName(), "namespace", obj.GetNamespace())
+	return r.Patch(ctx, handover, client.Apply, client.FieldOwner(ReplicasHandoverFieldManager))
+}
+
+// applyResource applies the resource by the server-side apply, only the fields which are composed
+// by the operator are owned by it, the fields set by the other controllers are kept. The resource
+// is updated with the response. All the generated resources are applied on every reconcile, so the
+// fields changed out of band are restored, except the beta ingresses which are created or updated
+// by createOrUpdateResource
+func (r *ErdaReconciler) applyResource(ctx context.Context, obj client.Object) error {
+	gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+	if err != nil {
+		return err
+	}
+	if err := helper.SetDesiredAnnotations(obj); err != nil {
+		return err
+	}
+	existing := obj.DeepCopyObject().(client.Object)
+	if err := r.Get(ctx, client.ObjectKeyFromObject(obj), existing); err == nil {
+		r.recordDesiredStateChange(existing, obj)
+		if err := r.upgradeManagedFields(ctx, existing); err != nil {
+			return err
 		}
+	} else if !k8sErrors.IsNotFound(err) {
+		return err
+	}
+
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	obj.SetResourceVersion("")
+	obj.SetManagedFields(nil)
+	return r.Patch(ctx, obj, client.Apply, client.FieldOwner(FieldManager), client.ForceOwnership)
+}
+
+// upgradeManagedFields moves the fields updated by the operator before it applies the resources
+// into its apply once, otherwise they are owned by the update forever and never removed
+func (r *ErdaReconciler) upgradeManagedFields(ctx context.Context, obj client.Object) error {
+	entries, upgraded := helper.UpgradeManagedFields(obj, CSAFieldManagers, FieldManager)
+	if !upgraded {
+		return nil
+	}
+	r.Log.Info("managed fields need to be upgraded", "name", obj.GetName(), "namespace", obj.GetNamespace())
+	patch, err := json.Marshal([]map[string]interface{}{
+		{"op": "test", "path": "/metadata/resourceVersion", "value": obj.GetResourceVersion()},
+		{"op": "replace", "path": "/metadata/managedFields", "value": entries},
+	})
+	if err != nil {
+		return err
 	}
-	if oldStatefulSet, ok := oldObj.(*appsv1.StatefulSet); ok {
-		newStatefulSet := newObj.(*appsv1.DaemonSet)
-		statefulSetSpec := helper.ComposeStatefulSetSpecFromK8sStatefulSet(oldStatefulSet)
-		if equal := deep.Equal(statefulSetSpec, newStatefulSet.Spec); equal != nil {
-			r.Log.Info(fmt.Sprintf("name: %s diff object is %+v", newStatefulSet.Name, equal))
-			return newStatefulSet, nil
+	return r.Patch(ctx, obj, client.RawPatch(types.JSONPatchType, patch))
+}
+
+// createOrUpdateResource creates the resource or updates it when the hash of its desired state changes
+// or the existing resource drifts from the desired state, it is only used for the beta ingresses since
+// the servers which only serve them may not support the apply. obj is the empty object of the same
+// kind to get the existing resource into
+func (r *ErdaReconciler) createOrUpdateResource(ctx context.Context, obj, newObj client.Object) error {
+	if err := helper.SetDesiredAnnotations(newObj); err != nil {
+		return err
+	}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(newObj), obj); err != nil {
+		if !k8sErrors.IsNotFound(err) {
+			return err
 		}
+		return r.Create(ctx, newObj)
 	}
-	if oldK8sService, ok := oldObj.(*corev1.Service); ok {
-		newK8sService := newObj.(*corev1.Service)
-		k8sServiceSpec := helper.ComposeKubernetesServiceSpecFromK8sService(oldK8sService)
-		if equal := deep.Equal(k8sServiceSpec, newK8sService.Spec); equal != nil {
-			newK8sService.ResourceVersion = oldK8sService.ResourceVersion
-			newK8sService.Spec.ClusterIP = oldK8sService.Spec.ClusterIP
-			r.Log.Info(fmt.Sprintf("name %s diff object is %+v", newK8sService.Name, equal))
-			return newK8sService, nil
+	if !r.recordDesiredStateChange(obj, newObj) {
+		drift := helper.ComposeDesiredStateDrift(obj, newObj)
+		if drift == "" {
+			return nil
 		}
+		r.Log.Info("resource drifts from the desired state", "kind", r.composeKind(newObj),
+			"name", newObj.GetName(), "namespace", newObj.GetNamespace(), "diff", drift)
 	}
+	newObj.SetResourceVersion(obj.GetResourceVersion())
+	return r.Update(ctx, newObj)
+}
 
-	if oldIngress, ok := oldObj.(*networkingv1.Ingress); ok {
-		newIngress := newObj.(*networkingv1.Ingress)
-		ingressSpec := helper.ComposeIngressV1SpecFromK8sIngress(oldIngress)
-		// spec deep equal
-		specEqual := deep.Equal(ingressSpec, newIngress.Spec)
-		annotationEqual := deep.Equal(oldIngress.Annotations, newIngress.Annotations)
-		if specEqual != nil || annotationEqual != nil {
-			if specEqual != nil && annotationEqual != nil {
-				r.Log.Info(fmt.Sprintf("name %s diff annotation object is %+v, spec object is %+v",
-					newIngress.Name, annotationEqual, specEqual))
-			} else if specEqual != nil {
-				r.Log.Info(fmt.Sprintf("name %s diff spec object is %+v", newIngress.Name, specEqual))
-			} else if annotationEqual != nil {
-				r.Log.Info(fmt.Sprintf("name %s diff annotation object is %+v", newIngress.Name, annotationEqual))
-			}
-			return newIngress, nil
-		}
+// recordDesiredStateChange reports whether the desired state of the resource changes, the diff from
+// the existing resource is logged and recorded as an Event of the resource if it changes
+func (r *ErdaReconciler) recordDesiredStateChange(obj, newObj client.Object) bool {
+	if !helper.IsDesiredStateChanged(obj, newObj) {
+		return false
 	}
-	return nil, nil
+	kind := r.composeKind(newObj)
+	diff := helper.ComposeDesiredStateDiff(obj, newObj)
+	r.Log.Info("resource need to be updated", "kind", kind, "name", newObj.GetName(),
+		"namespace", newObj.GetNamespace(), "diff", diff)
+	r.recordEvent(obj, corev1.EventTypeNormal, EventReasonDesiredStateChanged,
+		"%s %s is updated from the existing state: %s", kind, newObj.GetName(), diff)
+	return true
 }
 
-func (r *ErdaReconciler) deleteWorkLoad(obj client.Object) error {
+func (r *ErdaReconciler) deleteWorkLoad(erda *erdav1beta1.Erda, obj client.Object) error {
 	deleteOptions := client.DeleteOptions{}
 	deleteOptions.PropagationPolicy = utils.ConvertDeletePropagationToPoint(metav1.DeletePropagationBackground)
 
@@ -236,30 +525,73 @@ func (r *ErdaReconciler) deleteWorkLoad(obj client.Object) error {
 	if err != nil {
 		return client.IgnoreNotFound(err)
 	}
-	objKey := types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}
+	r.recordEvent(erda, corev1.EventTypeNormal, EventReasonDeleted, "%s %s is deleted",
+		r.composeKind(obj), obj.GetName())
+	return r.deleteComponentResources(types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()})
+}
 
-	r.Log.Info("service resource need to be deleted", "name", obj.GetName(), "namespace", obj.GetNamespace())
+// deleteComponentResources deletes the resources which are named after the component
+func (r *ErdaReconciler) deleteComponentResources(objKey types.NamespacedName) error {
+	if err := r.DeleteHorizontalPodAutoscaler(context.Background(), objKey); err != nil {
+		return err
+	}
+	if err := r.DeletePodDisruptionBudget(context.Background(), objKey); err != nil {
+		return err
+	}
+	if err := r.DeleteCanary(context.Background(), types.NamespacedName{
+		Name: helper.ComposeCanaryName(objKey.Name), Namespace: objKey.Namespace}); err != nil {
+		return err
+	}
+
+	r.Log.Info("service resource need to be deleted", "name", objKey.Name, "namespace", objKey.Namespace)
 	deleteServiceErr := r.DeleteKubernetesService(objKey)
 	if deleteServiceErr != nil {
 		return deleteServiceErr
 	}
+	if err := r.DeleteExternalServices(objKey); err != nil {
+		return err
+	}
+	if err := r.DeleteHeadlessService(objKey); err != nil {
+		return err
+	}
+	if err := r.DeleteOrdinalServices(context.Background(), objKey); err != nil {
+		return err
+	}
+	if err := r.DeleteIngressNginxStreams(context.Background(), objKey); err != nil {
+		return err
+	}
 
-	r.Log.Info("ingress resource need to be deleted", "name", obj.GetName(), "namespace", obj.GetNamespace())
+	r.Log.Info("ingress resource need to be deleted", "name", objKey.Name, "namespace", objKey.Namespace)
 	deleteIngressErr := r.DeleteIngress(objKey)
 	if deleteIngressErr != nil {
 		return deleteIngressErr
 
 	}
-	return nil
+
+	r.Log.Info("route resources need to be deleted", "name", objKey.Name, "namespace", objKey.Namespace)
+	return r.DeleteRoutes(context.Background(), objKey)
 }
 
-func (r *ErdaReconciler) SyncWorkLoadStatus(ctx context.Context, erda *erdav1beta1.Erda) error {
+// SyncWorkLoadStatus observes the workloads of the Erda into the status and collects the workloads which
+// are not in the spec, it returns the earliest time of the rollout checks which no workload event triggers
+func (r *ErdaReconciler) SyncWorkLoadStatus(ctx context.Context, erda *erdav1beta1.Erda,
+	hibernated, updated bool) (time.Time, error) {
 	workloadTypeList := []client.ObjectList{&appsv1.DeploymentList{}, &appsv1.DaemonSetList{}, &appsv1.StatefulSetList{}}
 
 	isDeploying := false
+	requeueTime := time.Time{}
+	observeRequeueTime := func(t time.Time) {
+		if !t.IsZero() && (requeueTime.IsZero() || t.Before(requeueTime)) {
+			requeueTime = t
+		}
+	}
 
 	// use component name with workflow type to primary key.
 	objs := map[string]client.Object{}
+	// the canary deployments are owned by the components, they are not collected
+	canaries := map[string]*appsv1.Deployment{}
+	// the colored deployments of the blue-green components, indexed by component name and color
+	colors := map[string]map[string]*appsv1.Deployment{}
 	for _, objList := range workloadTypeList {
 		err := r.List(context.Background(), objList,
 			client.InNamespace(erda.Namespace),
@@ -267,12 +599,24 @@ func (r *ErdaReconciler) SyncWorkLoadStatus(ctx context.Context, erda *erdav1bet
 		if err != nil {
 			errWrap := errors.Wrap(err, fmt.Sprintf("list %s error:", objList.GetObjectKind()))
 			r.Log.Error(errWrap, "sync workload status error")
-			return errWrap
+			return requeueTime, errWrap
 		}
 
 		switch v := objList.(type) {
 		case *appsv1.DeploymentList:
 			for _, item := range v.Items {
+				if name, ok := item.Labels[erdav1beta1.ErdaCanaryLabel]; ok {
+					canaries[name] = item.DeepCopy()
+					continue
+				}
+				if color, ok := item.Labels[erdav1beta1.ErdaColorLabel]; ok {
+					name := item.Labels[erdav1beta1.ErdaComponentLabel]
+					if colors[name] == nil {
+						colors[name] = map[string]*appsv1.Deployment{}
+					}
+					colors[name][color] = item.DeepCopy()
+					continue
+				}
 				objs[composeObjectName(item.Name, erdav1beta1.Stateless)] = item.DeepCopy()
 			}
 		case *appsv1.DaemonSetList:
@@ -286,12 +630,64 @@ func (r *ErdaReconciler) SyncWorkLoadStatus(ctx context.Context, erda *erdav1bet
 		}
 	}
 
+	// the pods are grouped by component to report the unhealthy containers
+	podList := &corev1.PodList{}
+	if err := r.List(ctx, podList, client.InNamespace(erda.Namespace),
+		client.HasLabels{erdav1beta1.ErdaComponentLabel}); err != nil {
+		return requeueTime, errors.Wrap(err, "list pods error")
+	}
+	pods := map[string][]corev1.Pod{}
+	for _, pod := range podList.Items {
+		name := pod.Labels[erdav1beta1.ErdaComponentLabel]
+		pods[name] = append(pods[name], pod)
+	}
+
 	appsStatus := make([]erdav1beta1.ApplicationStatus, 0, len(erda.Spec.Applications))
+	components := map[string]bool{}
+	replaced := map[string]bool{}
+	// the failure reasons and the unready reasons of the components, the Erda is degraded if any
+	failures, unready := []string{}, []string{}
 	for _, app := range erda.Spec.Applications {
-		allComponentsReady := true
+		allComponentsReady, appFailed, appDeploying := true, false, false
 		compStatus := make([]erdav1beta1.ComponentStatus, 0, len(app.Components))
 		for _, component := range app.Components {
+			components[component.Name] = true
 			searchName := composeObjectName(component.Name, component.WorkLoad)
+			if conflict := helper.ComposeRolloutConflict(&component); conflict != "" {
+				// the workloads of the rejected component are kept
+				delete(objs, searchName)
+				delete(colors, component.Name)
+				allComponentsReady, appFailed = false, true
+				failures = append(failures, fmt.Sprintf("component %s %s", component.Name, conflict))
+				compStatus = append(compStatus, erdav1beta1.ComponentStatus{
+					Name:   component.Name,
+					Status: erdav1beta1.StatusFailed,
+					Reason: conflict,
+				})
+				continue
+			}
+			if helper.IsBlueGreenEnabled(&component) {
+				// the Deployment before blue-green is replaced when the first color is switched to
+				delete(objs, searchName)
+				blueGreen := helper.ComposeBlueGreenStatus(&component, colors[component.Name])
+				status := r.getBlueGreenStatus(colors[component.Name], blueGreen.Active)
+				if status != erdav1beta1.StatusReady {
+					allComponentsReady = false
+					appDeploying = true
+					isDeploying = true
+				}
+				componentStatus := erdav1beta1.ComponentStatus{
+					Name:      component.Name,
+					Status:    status,
+					BlueGreen: blueGreen,
+				}
+				if deployment := colors[component.Name][blueGreen.Active]; deployment != nil {
+					helper.ComposePodsStatus(&componentStatus, deployment, pods[component.Name])
+				}
+				delete(colors, component.Name)
+				compStatus = append(compStatus, componentStatus)
+				continue
+			}
 			obj, ok := objs[searchName]
 			if !ok {
 				compStatus = append(compStatus, erdav1beta1.ComponentStatus{
@@ -302,17 +698,57 @@ func (r *ErdaReconciler) SyncWorkLoadStatus(ctx context.Context, erda *erdav1bet
 
 			delete(objs, searchName)
 
-			compStatus = append(compStatus, erdav1beta1.ComponentStatus{
-				Name: component.Name,
-				Status: func() erdav1beta1.StatusType {
-					status := r.getWorkLoadStatus(obj)
-					if status != erdav1beta1.StatusReady {
-						allComponentsReady = false
+			// the failed rollout is reported whether it is rolled back or not
+			status, reason := r.getWorkLoadStatus(obj), ""
+			if failure := helper.ComposeWorkloadFailure(obj, &component, time.Now()); failure != "" {
+				status, reason = erdav1beta1.StatusFailed, failure
+			} else if rollback := helper.ComposeRollbackReason(obj); rollback != "" {
+				status, reason = erdav1beta1.StatusFailed, rollback
+			} else if status == erdav1beta1.StatusDeploying && obj != nil && helper.IsWorkloadUpdated(obj) {
+				// the pods are not ready after the rollout, such as the restarted or evicted pods
+				status = erdav1beta1.StatusUnReady
+			}
+			// the colors of the component which is not released by blue-green any more are replaced
+			replaced[component.Name] = status == erdav1beta1.StatusReady
+			switch status {
+			case erdav1beta1.StatusReady:
+			case erdav1beta1.StatusFailed:
+				allComponentsReady, appFailed = false, true
+				failures = append(failures, fmt.Sprintf("component %s %s", component.Name, reason))
+			case erdav1beta1.StatusUnReady:
+				allComponentsReady = false
+			default:
+				allComponentsReady, appDeploying = false, true
+				isDeploying = true
+			}
+
+			componentStatus := erdav1beta1.ComponentStatus{
+				Name:   component.Name,
+				Status: status,
+				Reason: reason,
+				Canary: func() *erdav1beta1.CanaryStatus {
+					canary := helper.ComposeCanaryStatus(erda, &component, canaries[component.Name])
+					// the canary steps are advanced at the end of the pauses
+					if canary != nil && canary.Phase == erdav1beta1.CanaryProgressing {
+						appDeploying = true
 						isDeploying = true
+						observeRequeueTime(helper.ComposeCanaryRequeueTime(component.Canary, canaries[component.Name]))
 					}
-					return status
+					return canary
 				}(),
-			})
+			}
+			helper.ComposePodsStatus(&componentStatus, obj, pods[component.Name])
+			// the StatefulSet and the DaemonSet report no exceeded progress deadline by themselves
+			observeRequeueTime(helper.ComposeWorkloadDeadline(obj, &component))
+			if status == erdav1beta1.StatusUnReady {
+				message := fmt.Sprintf("component %s has %d/%d ready replicas", component.Name,
+					componentStatus.ReadyReplicas, componentStatus.Replicas)
+				if componentStatus.ContainerReason != "" {
+					message = fmt.Sprintf("%s, %s", message, componentStatus.ContainerReason)
+				}
+				unready = append(unready, message)
+			}
+			compStatus = append(compStatus, componentStatus)
 		}
 
 		appsStatus = append(appsStatus, erdav1beta1.ApplicationStatus{
@@ -322,6 +758,12 @@ func (r *ErdaReconciler) SyncWorkLoadStatus(ctx context.Context, erda *erdav1bet
 				if allComponentsReady {
 					return erdav1beta1.StatusReady
 				}
+				if appFailed {
+					return erdav1beta1.StatusFailed
+				}
+				if !appDeploying {
+					return erdav1beta1.StatusUnReady
+				}
 				return erdav1beta1.StatusDeploying
 			}(allComponentsReady),
 		})
@@ -333,24 +775,55 @@ func (r *ErdaReconciler) SyncWorkLoadStatus(ctx context.Context, erda *erdav1bet
 
 	erda.Status.Applications = appsStatus
 	// objs is not empty, means some workloads need to gc
-	if !isDeploying && len(objs) == 0 {
-		erda.Status.Phase = erdav1beta1.PhaseReady
-	} else {
-		erda.Status.Phase = erdav1beta1.PhaseDeploying
+	observation := helper.PhaseObservation{
+		Hibernated:  hibernated,
+		Failures:    failures,
+		Progressing: isDeploying || updated || len(objs) > 0 || len(colors) > 0,
+		Unready:     unready,
+	}
+	phase, reason := helper.ComposeWorkloadPhase(erda.Status, observation)
+	helper.TransitPhase(erda.Status, phase, reason, time.Now())
+	if phase == erdav1beta1.PhaseReady {
+		erda.Status.ObservedGeneration = erda.Generation
 	}
 
 	if err := r.Status().Update(ctx, erda); err != nil {
-		return err
+		return requeueTime, err
+	}
+	if err := r.SyncRevisionOutcome(ctx, erda, helper.ComposeRevisionOutcome(phase, observation)); err != nil {
+		return requeueTime, err
 	}
 
 	for _, obj := range objs {
-		if err := r.deleteWorkLoad(obj); err != nil {
+		if err := r.deleteWorkLoad(erda, obj); err != nil {
 			r.Log.Error(err, "delete workload error")
-			return err
+			return requeueTime, err
+		}
+	}
+	// the colored deployments of the components which are not released by blue-green any more, the
+	// active color keeps serving until the Deployment of the component is ready, and the resources
+	// of the component are deleted too if it is removed
+	for name, deployments := range colors {
+		active := helper.ComposeBlueGreenActiveColor(deployments)
+		for color, deployment := range deployments {
+			if components[name] && !replaced[name] && color == active {
+				continue
+			}
+			key := types.NamespacedName{Name: deployment.Name, Namespace: deployment.Namespace}
+			if err := r.deleteBlueGreenDeployment(ctx, key); err != nil {
+				r.Log.Error(err, "delete workload error")
+				return requeueTime, err
+			}
+		}
+		if !components[name] {
+			if err := r.deleteComponentResources(types.NamespacedName{Name: name, Namespace: erda.Namespace}); err != nil {
+				r.Log.Error(err, "delete workload error")
+				return requeueTime, err
+			}
 		}
 	}
 
-	return nil
+	return requeueTime, nil
 }
 
 func (r *ErdaReconciler) getWorkLoadStatus(object client.Object) erdav1beta1.StatusType {
@@ -360,7 +833,9 @@ func (r *ErdaReconciler) getWorkLoadStatus(object client.Object) erdav1beta1.Sta
 			return erdav1beta1.StatusReady
 		}
 	case *appsv1.Deployment:
-		if v.Status.AvailableReplicas == v.Status.Replicas && v.Status.UnavailableReplicas == 0 {
+		// the status of the spec which is not observed yet is out of date
+		if v.Status.ObservedGeneration >= v.Generation && v.Status.UpdatedReplicas == v.Status.Replicas &&
+			v.Status.AvailableReplicas == v.Status.Replicas && v.Status.UnavailableReplicas == 0 {
 			return erdav1beta1.StatusReady
 		}
 	case *appsv1.StatefulSet:
@@ -371,6 +846,21 @@ func (r *ErdaReconciler) getWorkLoadStatus(object client.Object) erdav1beta1.Sta
 	return erdav1beta1.StatusDeploying
 }
 
+// getBlueGreenStatus reports the blue-green component is ready when all the colors are ready,
+// it is deploying until the first color is switched to
+func (r *ErdaReconciler) getBlueGreenStatus(deployments map[string]*appsv1.Deployment,
+	active string) erdav1beta1.StatusType {
+	if active == "" {
+		return erdav1beta1.StatusDeploying
+	}
+	for _, deployment := range deployments {
+		if status := r.getWorkLoadStatus(deployment); status != erdav1beta1.StatusReady {
+			return status
+		}
+	}
+	return erdav1beta1.StatusReady
+}
+
 func (r *ErdaReconciler) VerifiedComponentStatus(erda erdav1beta1.Erda) bool {
 	for _, appStatus := range erda.Status.Applications {
 		if appStatus.Status != erdav1beta1.StatusReady {
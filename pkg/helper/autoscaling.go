@@ -0,0 +1,178 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"encoding/json"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+	"github.com/erda-project/erda-operator/pkg/utils"
+)
+
+const (
+	DefaultTargetCPUUtilization int32 = 80
+)
+
+// IsAutoscalingEnabled reports whether the replicas of the component is managed by the autoscaler
+func IsAutoscalingEnabled(component *erdav1beta1.Component) bool {
+	return component.Autoscaling != nil &&
+		(component.WorkLoad == erdav1beta1.Stateless || component.WorkLoad == erdav1beta1.Stateful)
+}
+
+// ComposeHorizontalPodAutoscaler composes the autoscaler of the component workload,
+// the defaults of Kubernetes are filled explicitly to keep the diff stable
+func ComposeHorizontalPodAutoscaler(component *erdav1beta1.Component,
+	references []metav1.OwnerReference) *autoscalingv2beta2.HorizontalPodAutoscaler {
+	autoscaling := component.Autoscaling
+
+	kind := "Deployment"
+	if component.WorkLoad == erdav1beta1.Stateful {
+		kind = "StatefulSet"
+	}
+	minReplicas := utils.ConvertInt32ToPointInt32(1)
+	if autoscaling.MinReplicas != nil {
+		minReplicas = autoscaling.MinReplicas
+	}
+
+	metrics := make([]autoscalingv2beta2.MetricSpec, 0, len(autoscaling.Metrics)+2)
+	if autoscaling.TargetCPUUtilization != nil {
+		metrics = append(metrics, composeResourceUtilizationMetric(corev1.ResourceCPU, *autoscaling.TargetCPUUtilization))
+	}
+	if autoscaling.TargetMemoryUtilization != nil {
+		metrics = append(metrics, composeResourceUtilizationMetric(corev1.ResourceMemory, *autoscaling.TargetMemoryUtilization))
+	}
+	metrics = append(metrics, autoscaling.Metrics...)
+	if len(metrics) == 0 {
+		metrics = append(metrics, composeResourceUtilizationMetric(corev1.ResourceCPU, DefaultTargetCPUUtilization))
+	}
+
+	return &autoscalingv2beta2.HorizontalPodAutoscaler{
+		ObjectMeta: utils.ComposeObjectMetadataFromComponent(component, references),
+		Spec: autoscalingv2beta2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2beta2.CrossVersionObjectReference{
+				APIVersion: appsv1.SchemeGroupVersion.String(),
+				Kind:       kind,
+				Name:       component.Name,
+			},
+			MinReplicas: minReplicas,
+			MaxReplicas: autoscaling.MaxReplicas,
+			Metrics:     metrics,
+		},
+	}
+}
+
+func composeResourceUtilizationMetric(name corev1.ResourceName, utilization int32) autoscalingv2beta2.MetricSpec {
+	return autoscalingv2beta2.MetricSpec{
+		Type: autoscalingv2beta2.ResourceMetricSourceType,
+		Resource: &autoscalingv2beta2.ResourceMetricSource{
+			Name: name,
+			Target: autoscalingv2beta2.MetricTarget{
+				Type:               autoscalingv2beta2.UtilizationMetricType,
+				AverageUtilization: utils.ConvertInt32ToPointInt32(utilization),
+			},
+		},
+	}
+}
+
+// OmitWorkloadReplicas leaves the replicas of the workload to the autoscaler by not applying it,
+// it returns the component replicas to resume the workload scaled to zero with since the autoscaler
+// does not scale it up
+func OmitWorkloadReplicas(oldObj, newObj client.Object) *int32 {
+	var replicas, oldReplicas *int32
+	switch newWorkload := newObj.(type) {
+	case *appsv1.Deployment:
+		replicas, newWorkload.Spec.Replicas = newWorkload.Spec.Replicas, nil
+		if oldWorkload, ok := oldObj.(*appsv1.Deployment); ok {
+			oldReplicas = oldWorkload.Spec.Replicas
+		}
+	case *appsv1.StatefulSet:
+		replicas, newWorkload.Spec.Replicas = newWorkload.Spec.Replicas, nil
+		if oldWorkload, ok := oldObj.(*appsv1.StatefulSet); ok {
+			oldReplicas = oldWorkload.Spec.Replicas
+		}
+	}
+	if !isScaledToZero(oldReplicas) {
+		return nil
+	}
+	if replicas == nil {
+		replicas = utils.ConvertInt32ToPointInt32(1)
+	}
+	return replicas
+}
+
+// IsReplicasAppliedBy reports whether the replicas of the workload is owned by the apply of the field
+// manager, it is removed when the manager applies the workload without it
+func IsReplicasAppliedBy(obj client.Object, manager string) bool {
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Manager != manager || entry.Operation != metav1.ManagedFieldsOperationApply ||
+			entry.FieldsV1 == nil {
+			continue
+		}
+		fields := make(map[string]interface{})
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		if spec, ok := fields["f:spec"].(map[string]interface{}); ok {
+			if _, ok := spec["f:replicas"]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ComposeReplicasHandover returns the apply configuration which only contains the live replicas of
+// the workload, it is applied by another field manager to share the ownership of the replicas, so
+// the replicas is kept when the operator stops applying it, and the autoscaler takes it over then
+func ComposeReplicasHandover(obj client.Object, gvk schema.GroupVersionKind) *unstructured.Unstructured {
+	var replicas *int32
+	switch workload := obj.(type) {
+	case *appsv1.Deployment:
+		replicas = workload.Spec.Replicas
+	case *appsv1.StatefulSet:
+		replicas = workload.Spec.Replicas
+	}
+	if replicas == nil {
+		return nil
+	}
+	handover := &unstructured.Unstructured{}
+	handover.SetGroupVersionKind(gvk)
+	handover.SetName(obj.GetName())
+	handover.SetNamespace(obj.GetNamespace())
+	handover.Object["spec"] = map[string]interface{}{"replicas": int64(*replicas)}
+	return handover
+}
+
+// SetWorkloadReplicas sets the replicas of the Deployment or the StatefulSet
+func SetWorkloadReplicas(obj client.Object, replicas *int32) {
+	switch workload := obj.(type) {
+	case *appsv1.Deployment:
+		workload.Spec.Replicas = replicas
+	case *appsv1.StatefulSet:
+		workload.Spec.Replicas = replicas
+	}
+}
+
+func isScaledToZero(replicas *int32) bool {
+	return replicas != nil && *replicas == 0
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-test/deep"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+	"github.com/erda-project/erda-operator/pkg/utils"
+)
+
+const (
+	BlueGreenBlue  = "blue"
+	BlueGreenGreen = "green"
+
+	DefaultBlueGreenRollbackWindow = 10 * time.Minute
+
+	// the revision of the Deployment which is set on it and its ReplicaSets by Kubernetes
+	deploymentRevisionAnnotation = "deployment.kubernetes.io/revision"
+)
+
+// IsBlueGreenEnabled reports whether the Stateless component is released by blue-green,
+// the component which is also released by canary is rejected by ComposeRolloutConflict
+func IsBlueGreenEnabled(component *erdav1beta1.Component) bool {
+	isStateless := component.WorkLoad == erdav1beta1.Stateless || component.WorkLoad == ""
+	return isStateless && component.Canary == nil &&
+		component.Rollout != nil && component.Rollout.BlueGreen != nil
+}
+
+// ComposeRolloutConflict returns the reason why the release of the component is rejected, the
+// blue-green release can not be combined with the canary release or the autoscaling, it is empty
+// if the component is valid
+func ComposeRolloutConflict(component *erdav1beta1.Component) string {
+	isStateless := component.WorkLoad == erdav1beta1.Stateless || component.WorkLoad == ""
+	if !isStateless || component.Rollout == nil || component.Rollout.BlueGreen == nil {
+		return ""
+	}
+	if component.Canary != nil {
+		return "blue-green release can not be combined with canary release"
+	}
+	if component.Autoscaling != nil {
+		return "blue-green release can not be combined with autoscaling"
+	}
+	return ""
+}
+
+// ComposeCurrentPodTemplateHash returns the pod template hash of the current ReplicaSet of the
+// Deployment, it is empty if the ReplicaSet is not found
+func ComposeCurrentPodTemplateHash(deployment *appsv1.Deployment, replicaSets []appsv1.ReplicaSet) string {
+	revision := deployment.Annotations[deploymentRevisionAnnotation]
+	for i := range replicaSets {
+		replicaSet := &replicaSets[i]
+		if metav1.IsControlledBy(replicaSet, deployment) && revision != "" &&
+			replicaSet.Annotations[deploymentRevisionAnnotation] == revision {
+			return replicaSet.Labels[appsv1.DefaultDeploymentUniqueLabelKey]
+		}
+	}
+	return ""
+}
+
+// ComposeBlueGreenName returns the name of the Deployment of the color
+func ComposeBlueGreenName(componentName, color string) string {
+	return fmt.Sprintf("%s-%s", componentName, color)
+}
+
+// ComposeBlueGreenOtherColor returns the color to release the new version, blue is
+// the first color when there is no active color
+func ComposeBlueGreenOtherColor(color string) string {
+	if color == BlueGreenBlue {
+		return BlueGreenGreen
+	}
+	return BlueGreenBlue
+}
+
+// ComposeBlueGreenDeployment composes the Deployment of the color, the pods keep the
+// component label and they are told apart by the color label
+func ComposeBlueGreenDeployment(component *erdav1beta1.Component, color string,
+	references []metav1.OwnerReference) *appsv1.Deployment {
+	colored := component.DeepCopy()
+	colored.Labels = utils.AppendLabels(utils.MergeMap(component.Labels, nil), map[string]string{
+		erdav1beta1.ErdaColorLabel: color,
+	})
+	deployment := ComposeDeployment(colored, references)
+	deployment.Name = ComposeBlueGreenName(component.Name, color)
+	return deployment
+}
+
+// IsBlueGreenTemplateEqual reports whether the deployment runs the pod template of the new deployment
+func IsBlueGreenTemplateEqual(deployment, newDeployment *appsv1.Deployment) bool {
+	return deep.Equal(ComposePodTemplateSpecFromPodTemplate(deployment.Spec.Template),
+		newDeployment.Spec.Template) == nil
+}
+
+// ComposeBlueGreenSwitchTime returns the time when the Service is switched to the deployment,
+// it is zero if the deployment has never been active
+func ComposeBlueGreenSwitchTime(deployment *appsv1.Deployment) time.Time {
+	if deployment == nil {
+		return time.Time{}
+	}
+	switchTime, err := time.Parse(time.RFC3339, deployment.Annotations[erdav1beta1.AnnotationBlueGreenSwitchTime])
+	if err != nil {
+		return time.Time{}
+	}
+	return switchTime
+}
+
+// ComposeBlueGreenActiveColor returns the color which is switched to most recently,
+// it is empty if none of the colors has been active
+func ComposeBlueGreenActiveColor(deployments map[string]*appsv1.Deployment) string {
+	active, activeTime := "", time.Time{}
+	for _, color := range []string{BlueGreenBlue, BlueGreenGreen} {
+		if switchTime := ComposeBlueGreenSwitchTime(deployments[color]); switchTime.After(activeTime) {
+			active, activeTime = color, switchTime
+		}
+	}
+	return active
+}
+
+// ComposeBlueGreenRollbackWindow returns the duration the previous color is kept after the switch
+func ComposeBlueGreenRollbackWindow(component *erdav1beta1.Component) time.Duration {
+	if IsBlueGreenEnabled(component) && component.Rollout.BlueGreen.RollbackWindow != nil {
+		return component.Rollout.BlueGreen.RollbackWindow.Duration
+	}
+	return DefaultBlueGreenRollbackWindow
+}
+
+// ComposeBlueGreenStatus returns the blue-green status of the component from the colored deployments
+func ComposeBlueGreenStatus(component *erdav1beta1.Component,
+	deployments map[string]*appsv1.Deployment) *erdav1beta1.BlueGreenStatus {
+	if !IsBlueGreenEnabled(component) {
+		return nil
+	}
+	status := &erdav1beta1.BlueGreenStatus{Active: ComposeBlueGreenActiveColor(deployments)}
+	if status.Active != "" && deployments[ComposeBlueGreenOtherColor(status.Active)] != nil {
+		deadline := metav1.NewTime(ComposeBlueGreenSwitchTime(deployments[status.Active]).
+			Add(ComposeBlueGreenRollbackWindow(component)))
+		status.RollbackDeadline = &deadline
+	}
+	return status
+}
+
+// ComposeBlueGreenRequeueTime returns the earliest rollback deadline in the future of the Erda
+// components, the previous colors are removed at the deadlines
+func ComposeBlueGreenRequeueTime(erda *erdav1beta1.Erda, now time.Time) time.Time {
+	requeueTime := time.Time{}
+	if erda.Status == nil {
+		return requeueTime
+	}
+	for _, app := range erda.Status.Applications {
+		for _, component := range app.Components {
+			if component.BlueGreen == nil || component.BlueGreen.RollbackDeadline == nil {
+				continue
+			}
+			deadline := component.BlueGreen.RollbackDeadline.Time
+			if deadline.After(now) && (requeueTime.IsZero() || deadline.Before(requeueTime)) {
+				requeueTime = deadline
+			}
+		}
+	}
+	return requeueTime
+}
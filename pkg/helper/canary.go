@@ -0,0 +1,261 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+	"github.com/erda-project/erda-operator/pkg/utils"
+)
+
+const (
+	AnnotationIngressNginxCanary             = "nginx.ingress.kubernetes.io/canary"
+	AnnotationIngressNginxCanaryWeight       = "nginx.ingress.kubernetes.io/canary-weight"
+	canaryPromoteToEnd                 int32 = -1
+)
+
+// ComposeCanaryName returns the name of the canary Deployment, Service and Ingress
+func ComposeCanaryName(componentName string) string {
+	return fmt.Sprintf("%s-canary", componentName)
+}
+
+// IsCanaryEnabled reports whether the component runs a canary release,
+// it is finished when the component image is updated to the canary image
+func IsCanaryEnabled(component *erdav1beta1.Component) bool {
+	// the workload of the component in spec is Stateless if it is empty
+	isStateless := component.WorkLoad == erdav1beta1.Stateless || component.WorkLoad == ""
+	return isStateless && component.Canary != nil &&
+		component.Canary.Image != "" && component.Canary.Image != component.ImageInfo.Image
+}
+
+// IsCanaryFinished reports whether the component image is updated to the canary image
+func IsCanaryFinished(component *erdav1beta1.Component) bool {
+	return component.Canary != nil && component.Canary.Image == component.ImageInfo.Image
+}
+
+// ComposeCanaryComponent returns the canary copy of the component, it is labeled
+// with its own name so the pods are not selected by the component Service
+func ComposeCanaryComponent(component *erdav1beta1.Component) *erdav1beta1.Component {
+	canary := component.DeepCopy()
+	canary.Name = ComposeCanaryName(component.Name)
+	canary.ImageInfo.Image = component.Canary.Image
+	canary.Autoscaling = nil
+	canary.DisruptionBudget = nil
+	canary.Labels = utils.AppendLabels(utils.MergeMap(component.Labels, nil), map[string]string{
+		erdav1beta1.ErdaCanaryLabel: component.Name,
+	})
+	canary.Replicas = utils.ConvertInt32ToPointInt32(1)
+	if component.Canary.Replicas != nil {
+		canary.Replicas = component.Canary.Replicas
+	}
+	// the hibernated component is not served by the canary either
+	if component.Replicas != nil && *component.Replicas == 0 {
+		canary.Replicas = utils.ConvertInt32ToPointInt32(0)
+	}
+	return canary
+}
+
+// ParseCanaryComponents parses the canary annotation of the Erda in format component[=step],...,
+// the step is -1 if it is not specified, which means the last step
+func ParseCanaryComponents(value string) map[string]int32 {
+	components := make(map[string]int32)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, step := item, canaryPromoteToEnd
+		if i := strings.Index(item, "="); i >= 0 {
+			name = item[:i]
+			if v, err := strconv.ParseInt(item[i+1:], 10, 32); err == nil {
+				step = int32(v)
+			}
+		}
+		components[name] = step
+	}
+	return components
+}
+
+// ComposeCanaryAborted returns the aborted canary images of the components in format component=image,...
+// The components in the abort annotation are resolved to their canary images, and the aborted canaries
+// which are removed or whose image changes are dropped, so the next canary image is released again
+func ComposeCanaryAborted(erda *erdav1beta1.Erda) string {
+	if erda.Spec == nil {
+		return ""
+	}
+	aborts := ParseCanaryComponents(erda.Annotations[erdav1beta1.AnnotationCanaryAbort])
+	aborted := parseCanaryAborted(erda.Annotations[erdav1beta1.AnnotationCanaryAborted])
+	items := make([]string, 0, len(aborts)+len(aborted))
+	for _, app := range erda.Spec.Applications {
+		for i := range app.Components {
+			component := &app.Components[i]
+			if !IsCanaryEnabled(component) {
+				continue
+			}
+			if _, ok := aborts[component.Name]; ok || aborted[component.Name] == component.Canary.Image {
+				items = append(items, fmt.Sprintf("%s=%s", component.Name, component.Canary.Image))
+			}
+		}
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+// IsCanaryAborted reports whether the current canary image of the component is aborted
+func IsCanaryAborted(erda *erdav1beta1.Erda, component *erdav1beta1.Component) bool {
+	if !IsCanaryEnabled(component) {
+		return false
+	}
+	return parseCanaryAborted(erda.Annotations[erdav1beta1.AnnotationCanaryAborted])[component.Name] ==
+		component.Canary.Image
+}
+
+func parseCanaryAborted(value string) map[string]string {
+	aborted := make(map[string]string)
+	for _, item := range strings.Split(value, ",") {
+		if i := strings.Index(item, "="); i > 0 {
+			aborted[strings.TrimSpace(item[:i])] = strings.TrimSpace(item[i+1:])
+		}
+	}
+	return aborted
+}
+
+// ComposeCanaryStep returns the current step of the canary and the time when the step starts.
+// The step is recorded on the canary Deployment, it restarts from the first step when the
+// canary image changes, and it is advanced when the pause elapses or by the promote annotation.
+func ComposeCanaryStep(canary *erdav1beta1.Canary, deployment *appsv1.Deployment,
+	promote map[string]int32, componentName string, now time.Time) (int32, time.Time) {
+	steps := int32(len(canary.Steps))
+	step, stepTime := int32(0), now
+	if deployment != nil && len(deployment.Spec.Template.Spec.Containers) > 0 &&
+		deployment.Spec.Template.Spec.Containers[0].Image == canary.Image {
+		if v, err := strconv.ParseInt(deployment.Annotations[erdav1beta1.AnnotationCanaryStep], 10, 32); err == nil {
+			step = int32(v)
+		}
+		if t, err := time.Parse(time.RFC3339, deployment.Annotations[erdav1beta1.AnnotationCanaryStepTime]); err == nil {
+			stepTime = t
+		}
+	}
+
+	if canary.Pause != nil && step < steps && now.Sub(stepTime) >= canary.Pause.Duration {
+		step, stepTime = step+1, now
+	}
+	if target, ok := promote[componentName]; ok {
+		if target == canaryPromoteToEnd || target > steps {
+			target = steps
+		}
+		if target > step {
+			step, stepTime = target, now
+		}
+	}
+	if step > steps {
+		step = steps
+	}
+	return step, stepTime
+}
+
+// ComposeCanaryWeight returns the traffic weight of the step, all traffic is
+// shifted to the canary after the last step
+func ComposeCanaryWeight(canary *erdav1beta1.Canary, step int32) int32 {
+	if step >= int32(len(canary.Steps)) {
+		return 100
+	}
+	weight := canary.Steps[step]
+	if weight < 0 {
+		return 0
+	}
+	if weight > 100 {
+		return 100
+	}
+	return weight
+}
+
+// ComposeCanaryPhase returns the phase of the canary at the step
+func ComposeCanaryPhase(canary *erdav1beta1.Canary, step int32) erdav1beta1.CanaryPhase {
+	switch {
+	case step >= int32(len(canary.Steps)):
+		return erdav1beta1.CanaryPromoted
+	case canary.Pause == nil:
+		return erdav1beta1.CanaryPaused
+	default:
+		return erdav1beta1.CanaryProgressing
+	}
+}
+
+// ComposeCanaryIngress composes the ingress of the canary component with
+// the ingress-nginx canary annotations of the weight
+func ComposeCanaryIngress(apiVersion string, canary *erdav1beta1.Component, references []metav1.OwnerReference,
+	defaultIngressClass string, weight int32) client.Object {
+	ingress := ComposeIngress(apiVersion, canary, references, defaultIngressClass)
+	ingress.SetAnnotations(utils.AppendLabels(utils.MergeMap(ingress.GetAnnotations(), nil), map[string]string{
+		AnnotationIngressNginxCanary:       "true",
+		AnnotationIngressNginxCanaryWeight: strconv.Itoa(int(weight)),
+	}))
+	return ingress
+}
+
+// ComposeCanaryStatus returns the canary status of the component from the canary Deployment,
+// it is nil if the component runs no canary release
+func ComposeCanaryStatus(erda *erdav1beta1.Erda, component *erdav1beta1.Component,
+	deployment *appsv1.Deployment) *erdav1beta1.CanaryStatus {
+	if !IsCanaryEnabled(component) {
+		return nil
+	}
+	if IsCanaryAborted(erda, component) {
+		return &erdav1beta1.CanaryStatus{
+			Image: component.Canary.Image,
+			Phase: erdav1beta1.CanaryAborted,
+		}
+	}
+	if deployment == nil {
+		return nil
+	}
+	step := int32(0)
+	if v, err := strconv.ParseInt(deployment.Annotations[erdav1beta1.AnnotationCanaryStep], 10, 32); err == nil {
+		step = int32(v)
+	}
+	return &erdav1beta1.CanaryStatus{
+		Image:  component.Canary.Image,
+		Step:   step,
+		Weight: ComposeCanaryWeight(component.Canary, step),
+		Phase:  ComposeCanaryPhase(component.Canary, step),
+	}
+}
+
+// ComposeCanaryRequeueTime returns the time when the canary advances to the next step by the pause,
+// it is zero if the canary is paused or promoted
+func ComposeCanaryRequeueTime(canary *erdav1beta1.Canary, deployment *appsv1.Deployment) time.Time {
+	if canary == nil || canary.Pause == nil || deployment == nil {
+		return time.Time{}
+	}
+	step, err := strconv.ParseInt(deployment.Annotations[erdav1beta1.AnnotationCanaryStep], 10, 32)
+	if err != nil || int32(step) >= int32(len(canary.Steps)) {
+		return time.Time{}
+	}
+	stepTime, err := time.Parse(time.RFC3339, deployment.Annotations[erdav1beta1.AnnotationCanaryStepTime])
+	if err != nil {
+		return time.Time{}
+	}
+	return stepTime.Add(canary.Pause.Duration)
+}
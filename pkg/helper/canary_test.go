@@ -0,0 +1,224 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+)
+
+func composeCanaryDeployment(image string, step int, stepTime time.Time) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+			erdav1beta1.AnnotationCanaryStep:     strconv.Itoa(step),
+			erdav1beta1.AnnotationCanaryStepTime: stepTime.UTC().Format(time.RFC3339),
+		}},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Image: image}},
+		}}},
+	}
+}
+
+func TestComposeCanaryStep(t *testing.T) {
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	pause := &metav1.Duration{Duration: 10 * time.Minute}
+
+	tests := []struct {
+		name         string
+		pause        *metav1.Duration
+		deployment   *appsv1.Deployment
+		promote      map[string]int32
+		wantStep     int32
+		wantStepTime time.Time
+	}{
+		{
+			name:         "the canary starts from the first step",
+			pause:        pause,
+			wantStep:     0,
+			wantStepTime: now,
+		},
+		{
+			name:         "the step is kept in the pause",
+			pause:        pause,
+			deployment:   composeCanaryDeployment("erda:2.0", 1, now.Add(-5*time.Minute)),
+			wantStep:     1,
+			wantStepTime: now.Add(-5 * time.Minute),
+		},
+		{
+			name:         "the step is advanced after the pause",
+			pause:        pause,
+			deployment:   composeCanaryDeployment("erda:2.0", 1, now.Add(-10*time.Minute)),
+			wantStep:     2,
+			wantStepTime: now,
+		},
+		{
+			name:         "the step is not advanced without the pause",
+			deployment:   composeCanaryDeployment("erda:2.0", 1, now.Add(-time.Hour)),
+			wantStep:     1,
+			wantStepTime: now.Add(-time.Hour),
+		},
+		{
+			name:         "the canary restarts when the image changes",
+			pause:        pause,
+			deployment:   composeCanaryDeployment("erda:1.5", 2, now.Add(-5*time.Minute)),
+			wantStep:     0,
+			wantStepTime: now,
+		},
+		{
+			name:         "the step is promoted",
+			deployment:   composeCanaryDeployment("erda:2.0", 0, now.Add(-time.Hour)),
+			promote:      map[string]int32{"web": 2},
+			wantStep:     2,
+			wantStepTime: now,
+		},
+		{
+			name:         "the step is promoted to the end",
+			deployment:   composeCanaryDeployment("erda:2.0", 0, now.Add(-time.Hour)),
+			promote:      map[string]int32{"web": canaryPromoteToEnd},
+			wantStep:     3,
+			wantStepTime: now,
+		},
+		{
+			name:         "the step beyond the last one is promoted to the end",
+			deployment:   composeCanaryDeployment("erda:2.0", 0, now.Add(-time.Hour)),
+			promote:      map[string]int32{"web": 10},
+			wantStep:     3,
+			wantStepTime: now,
+		},
+		{
+			name:         "the step is not promoted backwards",
+			deployment:   composeCanaryDeployment("erda:2.0", 2, now.Add(-time.Hour)),
+			promote:      map[string]int32{"web": 1, "api": 3},
+			wantStep:     2,
+			wantStepTime: now.Add(-time.Hour),
+		},
+		{
+			name:         "the last step is kept after the pause",
+			pause:        pause,
+			deployment:   composeCanaryDeployment("erda:2.0", 3, now.Add(-time.Hour)),
+			wantStep:     3,
+			wantStepTime: now.Add(-time.Hour),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			canary := &erdav1beta1.Canary{Image: "erda:2.0", Steps: []int32{10, 30, 60}, Pause: tt.pause}
+			step, stepTime := ComposeCanaryStep(canary, tt.deployment, tt.promote, "web", now)
+			if step != tt.wantStep || !stepTime.Equal(tt.wantStepTime) {
+				t.Errorf("ComposeCanaryStep() = (%d, %s), want (%d, %s)", step, stepTime, tt.wantStep, tt.wantStepTime)
+			}
+		})
+	}
+}
+
+func TestComposeCanaryWeightAndPhase(t *testing.T) {
+	tests := []struct {
+		name       string
+		pause      *metav1.Duration
+		step       int32
+		wantWeight int32
+		wantPhase  erdav1beta1.CanaryPhase
+	}{
+		{name: "first step", pause: &metav1.Duration{Duration: time.Minute}, step: 0, wantWeight: 10,
+			wantPhase: erdav1beta1.CanaryProgressing},
+		{name: "paused step", step: 0, wantWeight: 10, wantPhase: erdav1beta1.CanaryPaused},
+		{name: "weight above 100", step: 1, wantWeight: 100, wantPhase: erdav1beta1.CanaryPaused},
+		{name: "weight below 0", step: 2, wantWeight: 0, wantPhase: erdav1beta1.CanaryPaused},
+		{name: "promoted", step: 3, wantWeight: 100, wantPhase: erdav1beta1.CanaryPromoted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			canary := &erdav1beta1.Canary{Image: "erda:2.0", Steps: []int32{10, 150, -5}, Pause: tt.pause}
+			if weight := ComposeCanaryWeight(canary, tt.step); weight != tt.wantWeight {
+				t.Errorf("ComposeCanaryWeight() = %d, want %d", weight, tt.wantWeight)
+			}
+			if phase := ComposeCanaryPhase(canary, tt.step); phase != tt.wantPhase {
+				t.Errorf("ComposeCanaryPhase() = %s, want %s", phase, tt.wantPhase)
+			}
+		})
+	}
+}
+
+func TestComposeCanaryAborted(t *testing.T) {
+	composeCanaryComponent := func(name, image, canaryImage string) erdav1beta1.Component {
+		return erdav1beta1.Component{
+			Metadata: erdav1beta1.Metadata{Name: name},
+			ComponentSpec: erdav1beta1.ComponentSpec{
+				ImageInfo: erdav1beta1.ImageInfo{Image: image},
+				Canary:    &erdav1beta1.Canary{Image: canaryImage},
+			},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		abort      string
+		aborted    string
+		components []erdav1beta1.Component
+		want       string
+	}{
+		{
+			name:       "the aborts are resolved to the canary images",
+			abort:      "web, api",
+			components: []erdav1beta1.Component{composeCanaryComponent("web", "web:1", "web:2"), composeCanaryComponent("api", "api:1", "api:2")},
+			want:       "api=api:2,web=web:2",
+		},
+		{
+			name:       "the aborted canary image is kept",
+			aborted:    "web=web:2",
+			components: []erdav1beta1.Component{composeCanaryComponent("web", "web:1", "web:2")},
+			want:       "web=web:2",
+		},
+		{
+			name:       "the next canary image is not aborted",
+			aborted:    "web=web:2",
+			components: []erdav1beta1.Component{composeCanaryComponent("web", "web:1", "web:3")},
+		},
+		{
+			name:       "the finished canary is dropped",
+			abort:      "api",
+			aborted:    "web=web:2",
+			components: []erdav1beta1.Component{composeCanaryComponent("web", "web:2", "web:2"), composeCanaryComponent("api", "api:1", "")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			erda := composeTeardownErda("erda", tt.components...)
+			erda.Annotations = map[string]string{
+				erdav1beta1.AnnotationCanaryAbort:   tt.abort,
+				erdav1beta1.AnnotationCanaryAborted: tt.aborted,
+			}
+			got := ComposeCanaryAborted(&erda)
+			if got != tt.want {
+				t.Fatalf("ComposeCanaryAborted() = %q, want %q", got, tt.want)
+			}
+			erda.Annotations[erdav1beta1.AnnotationCanaryAborted] = got
+			for i := range tt.components {
+				component := &tt.components[i]
+				_, want := parseCanaryAborted(tt.want)[component.Name]
+				if aborted := IsCanaryAborted(&erda, component); aborted != want {
+					t.Errorf("IsCanaryAborted(%s) = %v, want %v", component.Name, aborted, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"strings"
+
+	"github.com/go-test/deep"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+)
+
+// the max length of the readable diff in the Event message
+const maxDesiredDiffLength = 1024
+
+// ComposeDesiredState returns the desired state of the rendered object to be hashed and diffed, the
+// metadata set by Kubernetes, the status and the annotations of the desired state are excluded
+func ComposeDesiredState(obj client.Object) (map[string]interface{}, error) {
+	var state map[string]interface{}
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		state = runtime.DeepCopyJSON(u.Object)
+	} else {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return nil, err
+		}
+		state = content
+	}
+
+	metadata := map[string]interface{}{}
+	if content, ok := state["metadata"].(map[string]interface{}); ok {
+		for _, key := range []string{"labels", "ownerReferences"} {
+			if value, ok := content[key]; ok {
+				metadata[key] = value
+			}
+		}
+	}
+	annotations := map[string]interface{}{}
+	for key, value := range obj.GetAnnotations() {
+		if key != erdav1beta1.AnnotationDesiredHash {
+			annotations[key] = value
+		}
+	}
+	if len(annotations) > 0 {
+		metadata["annotations"] = annotations
+	}
+	state["metadata"] = metadata
+	delete(state, "apiVersion")
+	delete(state, "kind")
+	delete(state, "status")
+	return state, nil
+}
+
+// SetDesiredAnnotations records the hash of the desired state in the annotations of the rendered
+// object, the desired state itself is not recorded to keep the annotations in the size limit
+func SetDesiredAnnotations(obj client.Object) error {
+	state, err := ComposeDesiredState(obj)
+	if err != nil {
+		return err
+	}
+	annotations := map[string]string{}
+	for key, value := range obj.GetAnnotations() {
+		annotations[key] = value
+	}
+	annotations[erdav1beta1.AnnotationDesiredHash] = ComposeHash(state)
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+// IsDesiredStateChanged reports whether the desired state of the new object differs from the
+// last applied one of the existing object
+func IsDesiredStateChanged(obj, newObj client.Object) bool {
+	return obj.GetAnnotations()[erdav1beta1.AnnotationDesiredHash] !=
+		newObj.GetAnnotations()[erdav1beta1.AnnotationDesiredHash]
+}
+
+// ComposeDesiredStateDiff returns the readable differences between the existing object and the
+// desired state of the new object, the existing object is pruned to the fields of the desired state,
+// so the fields defaulted by Kubernetes or set by the other controllers are not reported
+func ComposeDesiredStateDiff(obj, newObj client.Object) string {
+	live, err := ComposeDesiredState(obj)
+	if err != nil {
+		return "the existing state is not readable"
+	}
+	desired, err := ComposeDesiredState(newObj)
+	if err != nil {
+		return "the desired state is not readable"
+	}
+	if _, ok := obj.(*corev1.Secret); ok {
+		for _, state := range []map[string]interface{}{live, desired} {
+			delete(state, "data")
+			delete(state, "stringData")
+		}
+	}
+	diff := strings.Join(deep.Equal(pruneDesiredState(live, desired), desired), "; ")
+	if diff == "" {
+		// the pruned states are equal but the data of the Secret or the removed fields
+		diff = "the data or the removed fields are changed"
+	}
+	if len(diff) > maxDesiredDiffLength {
+		diff = diff[:maxDesiredDiffLength] + "..."
+	}
+	return diff
+}
+
+// ComposeDesiredStateDrift returns the differences of the existing object from the desired state of
+// the new object in the fields of the desired state, it is empty unless the fields are changed out of band
+func ComposeDesiredStateDrift(obj, newObj client.Object) string {
+	live, err := ComposeDesiredState(obj)
+	if err != nil {
+		return ""
+	}
+	desired, err := ComposeDesiredState(newObj)
+	if err != nil {
+		return ""
+	}
+	return strings.Join(deep.Equal(pruneDesiredState(live, desired), desired), "; ")
+}
+
+// pruneDesiredState returns the live value which only keeps the map keys of the desired value
+func pruneDesiredState(live, desired interface{}) interface{} {
+	switch desiredValue := desired.(type) {
+	case map[string]interface{}:
+		liveValue, ok := live.(map[string]interface{})
+		if !ok {
+			return live
+		}
+		pruned := make(map[string]interface{}, len(desiredValue))
+		for key, value := range desiredValue {
+			if liveField, ok := liveValue[key]; ok {
+				pruned[key] = pruneDesiredState(liveField, value)
+			}
+		}
+		return pruned
+	case []interface{}:
+		liveValue, ok := live.([]interface{})
+		if !ok || len(liveValue) != len(desiredValue) {
+			return live
+		}
+		pruned := make([]interface{}, len(liveValue))
+		for i := range liveValue {
+			pruned[i] = pruneDesiredState(liveValue[i], desiredValue[i])
+		}
+		return pruned
+	}
+	return live
+}
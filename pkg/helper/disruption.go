@@ -0,0 +1,67 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+	"github.com/erda-project/erda-operator/pkg/utils"
+)
+
+// IsDisruptionBudgetRequired reports whether the component needs a PodDisruptionBudget,
+// the minimum replicas of the autoscaler is taken into account
+func IsDisruptionBudgetRequired(component *erdav1beta1.Component) bool {
+	if component.WorkLoad != erdav1beta1.Stateless && component.WorkLoad != erdav1beta1.Stateful {
+		return false
+	}
+	replicas := int32(1)
+	if component.Replicas != nil {
+		replicas = *component.Replicas
+	}
+	if IsAutoscalingEnabled(component) && component.Autoscaling.MinReplicas != nil &&
+		*component.Autoscaling.MinReplicas > replicas {
+		replicas = *component.Autoscaling.MinReplicas
+	}
+	return replicas > 1
+}
+
+// ComposePodDisruptionBudget composes the PodDisruptionBudget of the component
+// which selects the same pods as the component service
+func ComposePodDisruptionBudget(component *erdav1beta1.Component,
+	references []metav1.OwnerReference) *policyv1beta1.PodDisruptionBudget {
+	pdb := &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: utils.ComposeObjectMetadataFromComponent(component, references),
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: ComposeComponentSelector(component),
+			},
+		},
+	}
+
+	budget := component.DisruptionBudget
+	switch {
+	case budget != nil && budget.MinAvailable != nil:
+		pdb.Spec.MinAvailable = budget.MinAvailable
+	case budget != nil && budget.MaxUnavailable != nil:
+		pdb.Spec.MaxUnavailable = budget.MaxUnavailable
+	default:
+		maxUnavailable := intstr.FromInt(1)
+		pdb.Spec.MaxUnavailable = &maxUnavailable
+	}
+	return pdb
+}
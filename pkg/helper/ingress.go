@@ -15,6 +15,9 @@
 package helper
 
 import (
+	"fmt"
+	"strings"
+
 	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/yaml"
@@ -23,11 +26,17 @@ import (
 	"github.com/erda-project/erda-operator/pkg/utils"
 )
 
-func ComposeIngressV1(component *erdav1beta1.Component, references []metav1.OwnerReference) *networkingv1.Ingress {
+const (
+	AnnotationIngressNginxBackendProtocol = "nginx.ingress.kubernetes.io/backend-protocol"
+)
+
+func ComposeIngressV1(component *erdav1beta1.Component, references []metav1.OwnerReference,
+	defaultIngressClass string) *networkingv1.Ingress {
 	ingress := &networkingv1.Ingress{
 		ObjectMeta: utils.ComposeObjectMetadataFromComponent(component, references),
 		Spec: networkingv1.IngressSpec{
-			Rules: composeRulesV1(component),
+			IngressClassName: ComposeIngressClassName(component, defaultIngressClass),
+			Rules:            composeRulesV1(component),
 			TLS: []networkingv1.IngressTLS{
 				{
 					Hosts: composeDomains(component),
@@ -36,70 +45,149 @@ func ComposeIngressV1(component *erdav1beta1.Component, references []metav1.Owne
 		},
 	}
 
-	if len(component.Annotations) == 0 {
+	// backend protocol snippet
+	if backendProtocol := composeBackendProtocol(component); backendProtocol != "" {
+		ingress.Annotations = map[string]string{
+			AnnotationIngressNginxBackendProtocol: backendProtocol,
+		}
+	}
+
+	// ingress snippet with annotation, the invalid annotations are reported by the controller
+	fmtIngAnnotations, err := ParseIngressAnnotations(component)
+	if err != nil || len(fmtIngAnnotations) == 0 {
 		return ingress
 	}
+	// the annotations which are written by user take precedence
+	ingress.Annotations = utils.MergeMap(ingress.Annotations, fmtIngAnnotations)
+
+	return ingress
+}
 
-	// ingress snippet with annotation
+// ParseIngressAnnotations returns the ingress annotations which are written by user in the
+// component annotation as yaml
+func ParseIngressAnnotations(component *erdav1beta1.Component) (map[string]string, error) {
 	ingAnnotations := component.Annotations[erdav1beta1.AnnotationIngressAnnotation]
 	if ingAnnotations == "" {
-		return ingress
+		return nil, nil
 	}
-
 	fmtIngAnnotations := make(map[string]string)
 	if err := yaml.Unmarshal([]byte(ingAnnotations), &fmtIngAnnotations); err != nil {
-		// TODO: error tips
-		return ingress
+		return nil, err
 	}
-	ingress.Annotations = fmtIngAnnotations
+	return fmtIngAnnotations, nil
+}
 
-	return ingress
+// composeBackendProtocol returns the ingress-nginx backend protocol of the domains,
+// it is set only if all domains use the same GRPC or HTTPS protocol, because
+// the annotation applies to the whole ingress
+func composeBackendProtocol(component *erdav1beta1.Component) string {
+	backendProtocol := ""
+	for _, sd := range component.Network.ServiceDiscovery {
+		if sd.Domain == "" {
+			continue
+		}
+		protocol := strings.ToUpper(sd.Protocol)
+		if protocol != GRPCProtocolType && protocol != HTTPSProtocolType {
+			return ""
+		}
+		if backendProtocol != "" && backendProtocol != protocol {
+			return ""
+		}
+		backendProtocol = protocol
+	}
+	return backendProtocol
 }
 
-func ComposeIngressV1SpecFromK8sIngress(ingress *networkingv1.Ingress) networkingv1.IngressSpec {
-	ingressSpec := networkingv1.IngressSpec{
-		TLS:   ingress.Spec.TLS,
-		Rules: ingress.Spec.Rules,
+// ComposeIngressClassName returns the ingress class of the component,
+// the component annotation overrides the operator default
+func ComposeIngressClassName(component *erdav1beta1.Component, defaultIngressClass string) *string {
+	ingressClass := defaultIngressClass
+	if v := component.Annotations[erdav1beta1.AnnotationIngressClass]; v != "" {
+		ingressClass = v
+	}
+	if ingressClass == "" {
+		return nil
 	}
-	return ingressSpec
+	return &ingressClass
+}
+
+// HasDomain reports whether any port of the component is exposed by the domain
+func HasDomain(component *erdav1beta1.Component) bool {
+	return component.Network != nil && len(composeDomains(component)) > 0
 }
 
 func composeDomains(component *erdav1beta1.Component) []string {
 	domains := make([]string, 0, len(component.Network.ServiceDiscovery))
+	visited := make(map[string]bool)
 	for _, sd := range component.Network.ServiceDiscovery {
-		if sd.Domain != "" {
+		if sd.Domain != "" && !visited[sd.Domain] {
+			visited[sd.Domain] = true
 			domains = append(domains, sd.Domain)
 		}
 	}
 	return domains
 }
 
+// composeRulesV1 groups the service discoveries by domain, every domain
+// is rendered as one rule which contains all paths of the domain
 func composeRulesV1(component *erdav1beta1.Component) []networkingv1.IngressRule {
 	ingressRules := make([]networkingv1.IngressRule, 0, len(component.Network.ServiceDiscovery))
+	ruleIndex := make(map[string]int)
+	pathVisited := make(map[string]bool)
 	for _, sd := range component.Network.ServiceDiscovery {
-		ingressRule := networkingv1.IngressRule{
-			Host: sd.Domain,
-			IngressRuleValue: networkingv1.IngressRuleValue{
-				HTTP: &networkingv1.HTTPIngressRuleValue{
-					Paths: []networkingv1.HTTPIngressPath{
-						{
-							Backend: networkingv1.IngressBackend{
-								Service: &networkingv1.IngressServiceBackend{
-									Name: component.Name,
-									Port: networkingv1.ServiceBackendPort{
-										Number: sd.Port,
-									},
-								},
-							},
-							Path:     sd.Path,
-							PathType: func(pathType networkingv1.PathType) *networkingv1.PathType { return &pathType }(networkingv1.PathTypeImplementationSpecific),
-						},
+		if sd.Domain == "" {
+			continue
+		}
+		pathType := ComposeIngressPathType(sd)
+		path := ComposeIngressPath(sd)
+		pathKey := fmt.Sprintf("%s%s", sd.Domain, path)
+		if pathVisited[pathKey] {
+			continue
+		}
+		pathVisited[pathKey] = true
+
+		index, ok := ruleIndex[sd.Domain]
+		if !ok {
+			ingressRules = append(ingressRules, networkingv1.IngressRule{
+				Host: sd.Domain,
+				IngressRuleValue: networkingv1.IngressRuleValue{
+					HTTP: &networkingv1.HTTPIngressRuleValue{},
+				},
+			})
+			index = len(ingressRules) - 1
+			ruleIndex[sd.Domain] = index
+		}
+		ingressRules[index].HTTP.Paths = append(ingressRules[index].HTTP.Paths, networkingv1.HTTPIngressPath{
+			Backend: networkingv1.IngressBackend{
+				Service: &networkingv1.IngressServiceBackend{
+					Name: component.Name,
+					Port: networkingv1.ServiceBackendPort{
+						Number: sd.Port,
 					},
 				},
 			},
-		}
-		ingressRules = append(ingressRules, ingressRule)
+			Path:     path,
+			PathType: &pathType,
+		})
 	}
 
 	return ingressRules
 }
+
+// ComposeIngressPathType returns the path type of service discovery,
+// ImplementationSpecific is default
+func ComposeIngressPathType(sd erdav1beta1.ServiceDiscovery) networkingv1.PathType {
+	if sd.PathType == "" {
+		return networkingv1.PathTypeImplementationSpecific
+	}
+	return sd.PathType
+}
+
+// ComposeIngressPath returns the path of service discovery, Exact and Prefix
+// path type require an absolute path, so the empty path will be set as root
+func ComposeIngressPath(sd erdav1beta1.ServiceDiscovery) string {
+	if sd.Path == "" && ComposeIngressPathType(sd) != networkingv1.PathTypeImplementationSpecific {
+		return "/"
+	}
+	return sd.Path
+}
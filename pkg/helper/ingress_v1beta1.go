@@ -0,0 +1,150 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+	"github.com/erda-project/erda-operator/pkg/utils"
+)
+
+const (
+	IngressAPIVersionV1                  = "networking.k8s.io/v1"
+	IngressAPIVersionNetworkingV1beta1   = "networking.k8s.io/v1beta1"
+	IngressAPIVersionExtensionsV1beta1   = "extensions/v1beta1"
+	AnnotationKubernetesIngressClassName = "kubernetes.io/ingress.class"
+)
+
+// NewIngress returns an empty ingress object of the given api version
+func NewIngress(apiVersion string) client.Object {
+	switch apiVersion {
+	case IngressAPIVersionNetworkingV1beta1:
+		return &networkingv1beta1.Ingress{}
+	case IngressAPIVersionExtensionsV1beta1:
+		return &extensionsv1beta1.Ingress{}
+	default:
+		return &networkingv1.Ingress{}
+	}
+}
+
+// ComposeIngress composes the ingress of the component with the given api version
+func ComposeIngress(apiVersion string, component *erdav1beta1.Component, references []metav1.OwnerReference,
+	defaultIngressClass string) client.Object {
+	ingress := ComposeIngressV1(component, references, defaultIngressClass)
+	switch apiVersion {
+	case IngressAPIVersionNetworkingV1beta1:
+		return ConvertIngressV1ToNetworkingV1beta1(ingress)
+	case IngressAPIVersionExtensionsV1beta1:
+		return ConvertIngressV1ToExtensionsV1beta1(ingress)
+	default:
+		return ingress
+	}
+}
+
+// ConvertIngressV1ToNetworkingV1beta1 converts the networking.k8s.io/v1 ingress
+// to networking.k8s.io/v1beta1, the ingress class is set by annotation
+// because the field is not served before Kubernetes 1.18
+func ConvertIngressV1ToNetworkingV1beta1(ingress *networkingv1.Ingress) *networkingv1beta1.Ingress {
+	newIngress := &networkingv1beta1.Ingress{
+		ObjectMeta: *ingress.ObjectMeta.DeepCopy(),
+	}
+	newIngress.Annotations = composeV1beta1IngressAnnotations(ingress)
+	for _, tls := range ingress.Spec.TLS {
+		newIngress.Spec.TLS = append(newIngress.Spec.TLS, networkingv1beta1.IngressTLS{
+			Hosts:      tls.Hosts,
+			SecretName: tls.SecretName,
+		})
+	}
+	for _, rule := range ingress.Spec.Rules {
+		newRule := networkingv1beta1.IngressRule{
+			Host: rule.Host,
+		}
+		if rule.HTTP != nil {
+			newRule.HTTP = &networkingv1beta1.HTTPIngressRuleValue{}
+			for _, path := range rule.HTTP.Paths {
+				newRule.HTTP.Paths = append(newRule.HTTP.Paths, networkingv1beta1.HTTPIngressPath{
+					Path:     path.Path,
+					PathType: (*networkingv1beta1.PathType)(composeV1beta1PathType(path.PathType)),
+					Backend: networkingv1beta1.IngressBackend{
+						ServiceName: path.Backend.Service.Name,
+						ServicePort: intstr.FromInt(int(path.Backend.Service.Port.Number)),
+					},
+				})
+			}
+		}
+		newIngress.Spec.Rules = append(newIngress.Spec.Rules, newRule)
+	}
+	return newIngress
+}
+
+// ConvertIngressV1ToExtensionsV1beta1 converts the networking.k8s.io/v1 ingress
+// to extensions/v1beta1, the ingress class is set by annotation
+// because the field is not served before Kubernetes 1.18
+func ConvertIngressV1ToExtensionsV1beta1(ingress *networkingv1.Ingress) *extensionsv1beta1.Ingress {
+	newIngress := &extensionsv1beta1.Ingress{
+		ObjectMeta: *ingress.ObjectMeta.DeepCopy(),
+	}
+	newIngress.Annotations = composeV1beta1IngressAnnotations(ingress)
+	for _, tls := range ingress.Spec.TLS {
+		newIngress.Spec.TLS = append(newIngress.Spec.TLS, extensionsv1beta1.IngressTLS{
+			Hosts:      tls.Hosts,
+			SecretName: tls.SecretName,
+		})
+	}
+	for _, rule := range ingress.Spec.Rules {
+		newRule := extensionsv1beta1.IngressRule{
+			Host: rule.Host,
+		}
+		if rule.HTTP != nil {
+			newRule.HTTP = &extensionsv1beta1.HTTPIngressRuleValue{}
+			for _, path := range rule.HTTP.Paths {
+				newRule.HTTP.Paths = append(newRule.HTTP.Paths, extensionsv1beta1.HTTPIngressPath{
+					Path:     path.Path,
+					PathType: (*extensionsv1beta1.PathType)(composeV1beta1PathType(path.PathType)),
+					Backend: extensionsv1beta1.IngressBackend{
+						ServiceName: path.Backend.Service.Name,
+						ServicePort: intstr.FromInt(int(path.Backend.Service.Port.Number)),
+					},
+				})
+			}
+		}
+		newIngress.Spec.Rules = append(newIngress.Spec.Rules, newRule)
+	}
+	return newIngress
+}
+
+// composeV1beta1PathType drops the default path type, the servers before
+// Kubernetes 1.18 do not serve the path type and the later ones default it
+func composeV1beta1PathType(pathType *networkingv1.PathType) *networkingv1.PathType {
+	if pathType == nil || *pathType == networkingv1.PathTypeImplementationSpecific {
+		return nil
+	}
+	return pathType
+}
+
+func composeV1beta1IngressAnnotations(ingress *networkingv1.Ingress) map[string]string {
+	if ingress.Spec.IngressClassName == nil {
+		return ingress.Annotations
+	}
+	return utils.AppendLabels(utils.MergeMap(ingress.Annotations, nil), map[string]string{
+		AnnotationKubernetesIngressClassName: *ingress.Spec.IngressClassName,
+	})
+}
@@ -30,7 +30,7 @@ var (
 func ComposeKubernetesJob(erdaName string, job *erdav1beta1.Job, references []metav1.OwnerReference) batchv1.Job {
 	return batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:            fmt.Sprintf("%s-%s-%s", erdaName, strings.ToLower(string(job.Type)), job.Name),
+			Name:            ComposeKubernetesJobName(erdaName, job),
 			Namespace:       job.Namespace,
 			Labels:          composeJobPodLabels(job),
 			Annotations:     job.Annotations,
@@ -49,6 +49,11 @@ func ComposeKubernetesJob(erdaName string, job *erdav1beta1.Job, references []me
 	}
 }
 
+// ComposeKubernetesJobName returns the name of the Kubernetes job of the Erda job
+func ComposeKubernetesJobName(erdaName string, job *erdav1beta1.Job) string {
+	return fmt.Sprintf("%s-%s-%s", erdaName, strings.ToLower(string(job.Type)), job.Name)
+}
+
 func composeJobPodLabels(job *erdav1beta1.Job) map[string]string {
 	if job.Labels == nil {
 		job.Labels = make(map[string]string)
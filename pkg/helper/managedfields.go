@@ -0,0 +1,96 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"encoding/json"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// UpgradeManagedFields moves the fields which are owned by the updates of the client-side managers
+// into the apply of the server-side manager, so the stale fields which were set by the update are
+// removed by the next apply without them. It returns the upgraded managed fields and whether they
+// are changed, the resource which is already applied by the server-side manager is not upgraded
+func UpgradeManagedFields(obj client.Object, csaManagers []string,
+	ssaManager string) ([]metav1.ManagedFieldsEntry, bool) {
+	isCSAManager := make(map[string]bool, len(csaManagers))
+	for _, manager := range csaManagers {
+		isCSAManager[manager] = true
+	}
+
+	var (
+		entries    []metav1.ManagedFieldsEntry
+		fields     map[string]interface{}
+		apiVersion string
+	)
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Manager == ssaManager && entry.Operation == metav1.ManagedFieldsOperationApply {
+			return nil, false
+		}
+		// the field sets of the different api versions can not be merged without the conversion
+		if !isCSAManager[entry.Manager] || entry.Operation != metav1.ManagedFieldsOperationUpdate ||
+			entry.FieldsV1 == nil || (apiVersion != "" && entry.APIVersion != apiVersion) {
+			entries = append(entries, entry)
+			continue
+		}
+		entryFields := make(map[string]interface{})
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &entryFields); err != nil {
+			entries = append(entries, entry)
+			continue
+		}
+		// the status is not applied with the resource
+		delete(entryFields, "f:status")
+		apiVersion = entry.APIVersion
+		fields = mergeFieldSets(fields, entryFields)
+	}
+	if fields == nil {
+		return nil, false
+	}
+
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return nil, false
+	}
+	now := metav1.NewTime(time.Now())
+	entries = append(entries, metav1.ManagedFieldsEntry{
+		Manager:    ssaManager,
+		Operation:  metav1.ManagedFieldsOperationApply,
+		APIVersion: apiVersion,
+		Time:       &now,
+		FieldsType: "FieldsV1",
+		FieldsV1:   &metav1.FieldsV1{Raw: raw},
+	})
+	return entries, true
+}
+
+// mergeFieldSets returns the union of the field sets
+func mergeFieldSets(dst, src map[string]interface{}) map[string]interface{} {
+	if dst == nil {
+		dst = make(map[string]interface{}, len(src))
+	}
+	for key, value := range src {
+		srcFields, ok := value.(map[string]interface{})
+		dstFields, exist := dst[key].(map[string]interface{})
+		if ok && exist {
+			dst[key] = mergeFieldSets(dstFields, srcFields)
+			continue
+		}
+		dst[key] = value
+	}
+	return dst
+}
@@ -0,0 +1,327 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+)
+
+const (
+	// LabelNamespaceName is set on every namespace by Kubernetes since 1.21
+	LabelNamespaceName = "kubernetes.io/metadata.name"
+	anyIPv4CIDR        = "0.0.0.0/0"
+)
+
+// IsNetworkPolicyEnabled reports whether the network policies of the Erda are generated,
+// the Erda annotation overwrites the operator default
+func IsNetworkPolicyEnabled(erda *erdav1beta1.Erda, defaultEnabled bool) bool {
+	if enabled, err := strconv.ParseBool(erda.Annotations[erdav1beta1.AnnotationNetworkPolicy]); err == nil {
+		return enabled
+	}
+	return defaultEnabled
+}
+
+// ComposeDefaultDenyNetworkPolicyName returns the name of the default deny policy of the Erda
+func ComposeDefaultDenyNetworkPolicyName(erdaName string) string {
+	return fmt.Sprintf("%s-default-deny", erdaName)
+}
+
+// ComposeNetworkPolicyName returns the name of the allow policy of the component
+func ComposeNetworkPolicyName(componentName string) string {
+	return fmt.Sprintf("%s-network-policy", componentName)
+}
+
+// composeNetworkPolicyPodNames returns the component label values of the pods of the components,
+// the canary pods are labeled as <name>-canary and are treated as the component
+func composeNetworkPolicyPodNames(componentNames ...string) []string {
+	podNames := make([]string, 0, 2*len(componentNames))
+	for _, name := range componentNames {
+		podNames = append(podNames, name, ComposeCanaryName(name))
+	}
+	sort.Strings(podNames)
+	return podNames
+}
+
+// ComposeNodeCIDRs returns the single host CIDRs of the node internal addresses,
+// which are the source addresses of the host network peers
+func ComposeNodeCIDRs(nodes []corev1.Node) []string {
+	cidrSet := make(map[string]bool)
+	for _, node := range nodes {
+		for _, address := range node.Status.Addresses {
+			if address.Type != corev1.NodeInternalIP {
+				continue
+			}
+			ip := net.ParseIP(address.Address)
+			if ip == nil {
+				continue
+			}
+			if ip.To4() != nil {
+				cidrSet[ip.String()+"/32"] = true
+			} else {
+				cidrSet[ip.String()+"/128"] = true
+			}
+		}
+	}
+	cidrs := make([]string, 0, len(cidrSet))
+	for cidr := range cidrSet {
+		cidrs = append(cidrs, cidr)
+	}
+	sort.Strings(cidrs)
+	return cidrs
+}
+
+// ComposeNetworkPolicies returns the default deny policy of the Erda and the allow policy
+// of every component. The component accepts the traffic from its own pods, the components
+// which depend on it by DependsOn, the job pods of the Erda, the ingress controller for the ports
+// with domain and the host network peers, so the component which is not declared in DependsOn is
+// denied. The canary pods are treated as the component on both sides of the policies.
+func ComposeNetworkPolicies(erda *erdav1beta1.Erda, ingressControllerNamespace string, hostCIDRs []string,
+	references []metav1.OwnerReference) []*networkingv1.NetworkPolicy {
+	// the job pods are not labeled with the component, they are selected by the job name
+	jobNames := make([]string, 0, len(erda.Spec.Jobs))
+	for _, job := range erda.Spec.Jobs {
+		jobNames = append(jobNames, job.Name)
+	}
+	sort.Strings(jobNames)
+	componentNames := make([]string, 0)
+	dependents := make(map[string][]string)
+	hostNetwork := make(map[string]bool)
+	for _, app := range erda.Spec.Applications {
+		for _, component := range app.Components {
+			componentNames = append(componentNames, component.Name)
+			if component.Network != nil && component.Network.Type == erdav1beta1.NetworkKindHost {
+				hostNetwork[component.Name] = true
+			}
+			for _, depend := range component.DependsOn {
+				dependents[depend] = append(dependents[depend], component.Name)
+			}
+		}
+	}
+	if len(componentNames) == 0 {
+		return nil
+	}
+	sort.Strings(componentNames)
+
+	policies := []*networkingv1.NetworkPolicy{
+		{
+			ObjectMeta: composeNetworkPolicyMetadata(erda, ComposeDefaultDenyNetworkPolicyName(erda.Name), references),
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{
+						{
+							Key:      erdav1beta1.ErdaComponentLabel,
+							Operator: metav1.LabelSelectorOpIn,
+							Values:   composeNetworkPolicyPodNames(componentNames...),
+						},
+					},
+				},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			},
+		},
+	}
+
+	for _, app := range erda.Spec.Applications {
+		for _, component := range app.Components {
+			// the network policy does not apply to the host network pods
+			if component.Network == nil || len(component.Network.ServiceDiscovery) == 0 ||
+				hostNetwork[component.Name] {
+				continue
+			}
+			policy := &networkingv1.NetworkPolicy{
+				ObjectMeta: composeNetworkPolicyMetadata(erda, ComposeNetworkPolicyName(component.Name), references),
+				Spec: networkingv1.NetworkPolicySpec{
+					PodSelector: metav1.LabelSelector{
+						MatchExpressions: []metav1.LabelSelectorRequirement{
+							{
+								Key:      erdav1beta1.ErdaComponentLabel,
+								Operator: metav1.LabelSelectorOpIn,
+								Values:   composeNetworkPolicyPodNames(component.Name),
+							},
+						},
+					},
+					Ingress: composeNetworkPolicyIngressRules(&component, dependents[component.Name], jobNames,
+						hostNetwork, ingressControllerNamespace, hostCIDRs),
+					PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+				},
+			}
+			policy.Labels[erdav1beta1.ErdaComponentLabel] = component.Name
+			policies = append(policies, policy)
+		}
+	}
+	return policies
+}
+
+func composeNetworkPolicyIngressRules(component *erdav1beta1.Component, dependents, jobNames []string,
+	hostNetwork map[string]bool, ingressControllerNamespace string, hostCIDRs []string) []networkingv1.NetworkPolicyIngressRule {
+	allPorts := make([]networkingv1.NetworkPolicyPort, 0)
+	ingressPorts := make([]networkingv1.NetworkPolicyPort, 0)
+	rules := []networkingv1.NetworkPolicyIngressRule{
+		{
+			From: []networkingv1.NetworkPolicyPeer{
+				{
+					PodSelector: &metav1.LabelSelector{
+						MatchExpressions: []metav1.LabelSelectorRequirement{
+							{
+								Key:      erdav1beta1.ErdaComponentLabel,
+								Operator: metav1.LabelSelectorOpIn,
+								Values:   composeNetworkPolicyPodNames(component.Name),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	seen := make(map[string]bool)
+	for _, sd := range component.Network.ServiceDiscovery {
+		port := composeNetworkPolicyPort(sd.Protocol, sd.Port)
+		key := fmt.Sprintf("%s/%d", *port.Protocol, sd.Port)
+		if !seen[key] {
+			allPorts = append(allPorts, port)
+		}
+		if sd.Domain != "" || (sd.Expose != nil && sd.Expose.Type == erdav1beta1.ExposeIngressNginx) {
+			if !seen[key+"/ingress"] {
+				ingressPorts = append(ingressPorts, port)
+			}
+			seen[key+"/ingress"] = true
+		}
+		seen[key] = true
+
+		// the ports exposed by NodePort and LoadBalancer accept the clients in the source ranges
+		if sd.Expose != nil && (sd.Expose.Type == erdav1beta1.ExposeNodePort ||
+			sd.Expose.Type == erdav1beta1.ExposeLoadBalancer) {
+			sourceRanges := sd.Expose.SourceRanges
+			if len(sourceRanges) == 0 {
+				sourceRanges = []string{anyIPv4CIDR}
+			}
+			rules = append(rules, networkingv1.NetworkPolicyIngressRule{
+				Ports: []networkingv1.NetworkPolicyPort{port},
+				From:  composeIPBlockPeers(sourceRanges),
+			})
+		}
+	}
+
+	podDependents := make([]string, 0, len(dependents))
+	for _, dependent := range dependents {
+		if !hostNetwork[dependent] && dependent != component.Name {
+			podDependents = append(podDependents, dependent)
+		}
+	}
+	if len(podDependents) > 0 {
+		rules = append(rules, networkingv1.NetworkPolicyIngressRule{
+			Ports: allPorts,
+			From: []networkingv1.NetworkPolicyPeer{
+				{
+					PodSelector: &metav1.LabelSelector{
+						MatchExpressions: []metav1.LabelSelectorRequirement{
+							{
+								Key:      erdav1beta1.ErdaComponentLabel,
+								Operator: metav1.LabelSelectorOpIn,
+								Values:   composeNetworkPolicyPodNames(podDependents...),
+							},
+						},
+					},
+				},
+			},
+		})
+	}
+
+	if len(jobNames) > 0 {
+		rules = append(rules, networkingv1.NetworkPolicyIngressRule{
+			Ports: allPorts,
+			From: []networkingv1.NetworkPolicyPeer{
+				{
+					PodSelector: &metav1.LabelSelector{
+						MatchExpressions: []metav1.LabelSelectorRequirement{
+							{
+								Key:      erdav1beta1.ErdaJobNameLabel,
+								Operator: metav1.LabelSelectorOpIn,
+								Values:   jobNames,
+							},
+						},
+					},
+				},
+			},
+		})
+	}
+
+	if len(ingressPorts) > 0 && ingressControllerNamespace != "" {
+		rules = append(rules, networkingv1.NetworkPolicyIngressRule{
+			Ports: ingressPorts,
+			From: []networkingv1.NetworkPolicyPeer{
+				{
+					NamespaceSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{
+							LabelNamespaceName: ingressControllerNamespace,
+						},
+					},
+				},
+			},
+		})
+	}
+
+	if len(hostCIDRs) > 0 {
+		rules = append(rules, networkingv1.NetworkPolicyIngressRule{
+			Ports: allPorts,
+			From:  composeIPBlockPeers(hostCIDRs),
+		})
+	}
+	return rules
+}
+
+func composeNetworkPolicyPort(protocol string, port int32) networkingv1.NetworkPolicyPort {
+	k8sProtocol := GetKubernetesProtocol(protocol)
+	policyPort := intstr.FromInt(int(port))
+	return networkingv1.NetworkPolicyPort{
+		Protocol: &k8sProtocol,
+		Port:     &policyPort,
+	}
+}
+
+func composeIPBlockPeers(cidrs []string) []networkingv1.NetworkPolicyPeer {
+	peers := make([]networkingv1.NetworkPolicyPeer, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{
+			IPBlock: &networkingv1.IPBlock{
+				CIDR: cidr,
+			},
+		})
+	}
+	return peers
+}
+
+func composeNetworkPolicyMetadata(erda *erdav1beta1.Erda, name string,
+	references []metav1.OwnerReference) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      name,
+		Namespace: erda.Namespace,
+		Labels: map[string]string{
+			erdav1beta1.ErdaOperatorLabel: "true",
+			erdav1beta1.ErdaNameLabel:     erda.Name,
+		},
+		OwnerReferences: references,
+	}
+}
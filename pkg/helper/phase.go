@@ -0,0 +1,106 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+)
+
+// MaxPhaseTransitions is the count of the phase transitions kept in the status
+const MaxPhaseTransitions = 10
+
+// PhaseObservation is the observation of the workloads which decides the phase of the Erda
+type PhaseObservation struct {
+	Hibernated bool
+	// Failures are the failed rollouts of the components
+	Failures []string
+	// Progressing means the workloads are being created, updated or removed
+	Progressing bool
+	// Unready are the components whose pods are not all ready after the rollout
+	Unready []string
+}
+
+// ComposeWorkloadPhase returns the phase of the Erda from the workloads and the reason:
+//
+//	Deleting                          -> Deleting, it is terminal
+//	any                               -> Hibernated, when hibernated
+//	any                               -> Degraded, when any rollout is failed
+//	Initialization, Deploying, empty  -> Deploying, while progressing before the first Ready
+//	Ready, Upgrading, Degraded, ...   -> Upgrading, while progressing after the first Ready
+//	any                               -> Degraded, when the pods are not all ready after the rollouts
+//	any                               -> Ready
+func ComposeWorkloadPhase(status *erdav1beta1.ErdaStatus, observation PhaseObservation) (erdav1beta1.PhaseType, string) {
+	switch {
+	case status.Phase == erdav1beta1.PhaseDeleting:
+		return erdav1beta1.PhaseDeleting, status.Reason
+	case observation.Hibernated:
+		return erdav1beta1.PhaseHibernated, ""
+	case len(observation.Failures) > 0:
+		return erdav1beta1.PhaseDegraded, strings.Join(observation.Failures, "; ")
+	case observation.Progressing && status.ObservedGeneration == 0:
+		return erdav1beta1.PhaseDeploying, ""
+	case observation.Progressing:
+		return erdav1beta1.PhaseUpgrading, ""
+	case len(observation.Unready) > 0:
+		return erdav1beta1.PhaseDegraded, strings.Join(observation.Unready, "; ")
+	}
+	return erdav1beta1.PhaseReady, ""
+}
+
+// TransitPhase sets the phase of the Erda, the transition is recorded when the phase changes
+func TransitPhase(status *erdav1beta1.ErdaStatus, phase erdav1beta1.PhaseType, reason string, now time.Time) {
+	status.Reason = reason
+	if status.Phase == phase {
+		return
+	}
+	transitionTime := metav1.NewTime(now)
+	status.Phase = phase
+	status.LastTransitionTime = &transitionTime
+	status.Transitions = append(status.Transitions, erdav1beta1.PhaseTransition{
+		Phase:  phase,
+		Reason: reason,
+		Time:   transitionTime,
+	})
+	if len(status.Transitions) > MaxPhaseTransitions {
+		status.Transitions = status.Transitions[len(status.Transitions)-MaxPhaseTransitions:]
+	}
+}
+
+// IsWorkloadUpdated reports whether all the pods of the workload run the latest pod template,
+// the updated workload which is not ready is unready rather than progressing
+func IsWorkloadUpdated(obj client.Object) bool {
+	switch v := obj.(type) {
+	case *appsv1.Deployment:
+		replicas := int32(1)
+		if v.Spec.Replicas != nil {
+			replicas = *v.Spec.Replicas
+		}
+		return v.Status.ObservedGeneration >= v.Generation && v.Status.UpdatedReplicas == replicas &&
+			v.Status.Replicas == replicas
+	case *appsv1.StatefulSet:
+		return v.Status.ObservedGeneration >= v.Generation && v.Status.UpdatedReplicas >= composeStatefulSetUpdatedReplicas(v)
+	case *appsv1.DaemonSet:
+		return v.Status.ObservedGeneration >= v.Generation &&
+			v.Status.UpdatedNumberScheduled == v.Status.DesiredNumberScheduled
+	}
+	return false
+}
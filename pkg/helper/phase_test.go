@@ -0,0 +1,168 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+)
+
+func TestComposeWorkloadPhase(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      erdav1beta1.ErdaStatus
+		observation PhaseObservation
+		wantPhase   erdav1beta1.PhaseType
+		wantReason  string
+	}{
+		{
+			name:        "deleting is terminal",
+			status:      erdav1beta1.ErdaStatus{Phase: erdav1beta1.PhaseDeleting, Reason: "deleting application a"},
+			observation: PhaseObservation{Hibernated: true, Failures: []string{"component a failed"}},
+			wantPhase:   erdav1beta1.PhaseDeleting,
+			wantReason:  "deleting application a",
+		},
+		{
+			name:        "hibernated",
+			status:      erdav1beta1.ErdaStatus{Phase: erdav1beta1.PhaseReady},
+			observation: PhaseObservation{Hibernated: true, Failures: []string{"component a failed"}},
+			wantPhase:   erdav1beta1.PhaseHibernated,
+		},
+		{
+			name:   "failed rollout",
+			status: erdav1beta1.ErdaStatus{Phase: erdav1beta1.PhaseUpgrading, ObservedGeneration: 1},
+			observation: PhaseObservation{
+				Failures:    []string{"component a failed", "component b failed"},
+				Progressing: true,
+			},
+			wantPhase:  erdav1beta1.PhaseDegraded,
+			wantReason: "component a failed; component b failed",
+		},
+		{
+			name:        "progressing before the first ready",
+			status:      erdav1beta1.ErdaStatus{Phase: erdav1beta1.PhaseInitialization},
+			observation: PhaseObservation{Progressing: true},
+			wantPhase:   erdav1beta1.PhaseDeploying,
+		},
+		{
+			name:        "progressing after the first ready",
+			status:      erdav1beta1.ErdaStatus{Phase: erdav1beta1.PhaseReady, ObservedGeneration: 2},
+			observation: PhaseObservation{Progressing: true, Unready: []string{"component a unready"}},
+			wantPhase:   erdav1beta1.PhaseUpgrading,
+		},
+		{
+			name:        "unready after the rollout",
+			status:      erdav1beta1.ErdaStatus{Phase: erdav1beta1.PhaseReady, ObservedGeneration: 2},
+			observation: PhaseObservation{Unready: []string{"component a unready", "component b unready"}},
+			wantPhase:   erdav1beta1.PhaseDegraded,
+			wantReason:  "component a unready; component b unready",
+		},
+		{
+			name:      "ready",
+			status:    erdav1beta1.ErdaStatus{Phase: erdav1beta1.PhaseDegraded, Reason: "component a unready"},
+			wantPhase: erdav1beta1.PhaseReady,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			phase, reason := ComposeWorkloadPhase(&tt.status, tt.observation)
+			if phase != tt.wantPhase || reason != tt.wantReason {
+				t.Errorf("ComposeWorkloadPhase() = (%s, %q), want (%s, %q)", phase, reason, tt.wantPhase, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestTransitPhase(t *testing.T) {
+	then := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := then.Add(time.Hour)
+	composeTransitions := func(count int) []erdav1beta1.PhaseTransition {
+		transitions := make([]erdav1beta1.PhaseTransition, 0, count)
+		for i := 0; i < count; i++ {
+			transitions = append(transitions, erdav1beta1.PhaseTransition{
+				Phase:  erdav1beta1.PhaseUpgrading,
+				Reason: fmt.Sprintf("transition %d", i),
+				Time:   metav1.NewTime(then),
+			})
+		}
+		transitions[count-1].Phase = erdav1beta1.PhaseReady
+		return transitions
+	}
+
+	tests := []struct {
+		name            string
+		transitions     []erdav1beta1.PhaseTransition
+		phase           erdav1beta1.PhaseType
+		wantTime        time.Time
+		wantTransitions int
+		wantFirstReason string
+	}{
+		{
+			name:            "same phase",
+			transitions:     composeTransitions(1),
+			phase:           erdav1beta1.PhaseReady,
+			wantTime:        then,
+			wantTransitions: 1,
+			wantFirstReason: "transition 0",
+		},
+		{
+			name:            "changed phase",
+			transitions:     composeTransitions(1),
+			phase:           erdav1beta1.PhaseUpgrading,
+			wantTime:        now,
+			wantTransitions: 2,
+			wantFirstReason: "transition 0",
+		},
+		{
+			name:            "the oldest transition is dropped beyond the cap",
+			transitions:     composeTransitions(MaxPhaseTransitions),
+			phase:           erdav1beta1.PhaseUpgrading,
+			wantTime:        now,
+			wantTransitions: MaxPhaseTransitions,
+			wantFirstReason: "transition 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transitionTime := metav1.NewTime(then)
+			status := &erdav1beta1.ErdaStatus{
+				Phase:              erdav1beta1.PhaseReady,
+				LastTransitionTime: &transitionTime,
+				Transitions:        tt.transitions,
+			}
+			TransitPhase(status, tt.phase, "new reason", now)
+			if status.Phase != tt.phase || status.Reason != "new reason" {
+				t.Errorf("phase = (%s, %q), want (%s, %q)", status.Phase, status.Reason, tt.phase, "new reason")
+			}
+			if !status.LastTransitionTime.Time.Equal(tt.wantTime) {
+				t.Errorf("last transition time = %s, want %s", status.LastTransitionTime.Time, tt.wantTime)
+			}
+			if len(status.Transitions) != tt.wantTransitions {
+				t.Fatalf("transitions = %d, want %d", len(status.Transitions), tt.wantTransitions)
+			}
+			if reason := status.Transitions[0].Reason; reason != tt.wantFirstReason {
+				t.Errorf("first transition reason = %q, want %q", reason, tt.wantFirstReason)
+			}
+			if last := status.Transitions[len(status.Transitions)-1]; last.Phase != tt.phase {
+				t.Errorf("last transition phase = %s, want %s", last.Phase, tt.phase)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+	"github.com/erda-project/erda-operator/pkg/utils"
+)
+
+const (
+	ServiceRegistryJSONKey = "registry.json"
+	ServiceRegistryYAMLKey = "registry.yaml"
+)
+
+// ServiceRegistry describes the endpoints of all components in an Erda
+type ServiceRegistry struct {
+	Applications []ApplicationRegistry `json:"applications"`
+}
+
+type ApplicationRegistry struct {
+	Name       string              `json:"name"`
+	Components []ComponentRegistry `json:"components"`
+}
+
+type ComponentRegistry struct {
+	Name      string                 `json:"name"`
+	Address   string                 `json:"address,omitempty"`
+	PublicURL string                 `json:"publicURL,omitempty"`
+	Ports     []PortRegistry         `json:"ports,omitempty"`
+	Status    erdav1beta1.StatusType `json:"status"`
+	Ready     bool                   `json:"ready"`
+}
+
+type PortRegistry struct {
+	Port      int32  `json:"port"`
+	Protocol  string `json:"protocol"`
+	Address   string `json:"address"`
+	PublicURL string `json:"publicURL,omitempty"`
+}
+
+// ComposeServiceRegistryName returns the name of the service registry ConfigMap of the Erda
+func ComposeServiceRegistryName(erdaName string) string {
+	return fmt.Sprintf("%s-service-registry", erdaName)
+}
+
+// ComposeServiceRegistry builds the service registry from the Erda spec in the same way
+// as the dependency env vars, and the readiness from the Erda status
+func ComposeServiceRegistry(erda *erdav1beta1.Erda, clusterDomain string) ServiceRegistry {
+	componentStatus := make(map[string]erdav1beta1.StatusType)
+	if erda.Status != nil {
+		for _, appStatus := range erda.Status.Applications {
+			for _, compStatus := range appStatus.Components {
+				componentStatus[appStatus.Name+"/"+compStatus.Name] = compStatus.Status
+			}
+		}
+	}
+
+	registry := ServiceRegistry{
+		Applications: make([]ApplicationRegistry, 0, len(erda.Spec.Applications)),
+	}
+	for _, app := range erda.Spec.Applications {
+		appRegistry := ApplicationRegistry{
+			Name:       app.Name,
+			Components: make([]ComponentRegistry, 0, len(app.Components)),
+		}
+		scheme := utils.ParseProtocol(app.Annotations[erdav1beta1.AnnotationSSLEnabled])
+		for _, component := range app.Components {
+			status, ok := componentStatus[app.Name+"/"+component.Name]
+			if !ok {
+				status = erdav1beta1.StatusUnKnown
+			}
+			compRegistry := ComponentRegistry{
+				Name:   component.Name,
+				Status: status,
+				Ready:  status == erdav1beta1.StatusReady,
+			}
+			if component.Network != nil && component.WorkLoad != erdav1beta1.PerNode &&
+				component.Network.Type != erdav1beta1.NetworkKindHost {
+				format := utils.ComposeServiceAddressFormat(app.Annotations, component.Annotations)
+				for index, sd := range component.Network.ServiceDiscovery {
+					port := PortRegistry{
+						Port:     sd.Port,
+						Protocol: strings.ToUpper(sd.Protocol),
+						Address:  utils.ComposeServiceAddress(component.Name, erda.Namespace, sd.Port, format, clusterDomain),
+					}
+					if sd.Domain != "" {
+						port.PublicURL = fmt.Sprintf("%s://%s%s", scheme, sd.Domain, sd.Path)
+					}
+					if index == 0 {
+						compRegistry.Address = port.Address
+						compRegistry.PublicURL = port.PublicURL
+					}
+					compRegistry.Ports = append(compRegistry.Ports, port)
+				}
+			}
+			appRegistry.Components = append(appRegistry.Components, compRegistry)
+		}
+		registry.Applications = append(registry.Applications, appRegistry)
+	}
+	return registry
+}
+
+// ComposeServiceRegistryConfigMap renders the service registry as JSON and YAML in a ConfigMap
+func ComposeServiceRegistryConfigMap(erda *erdav1beta1.Erda, clusterDomain string,
+	references []metav1.OwnerReference) (*corev1.ConfigMap, error) {
+	registry := ComposeServiceRegistry(erda, clusterDomain)
+	jsonData, err := json.MarshalIndent(registry, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	yamlData, err := yaml.Marshal(registry)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            ComposeServiceRegistryName(erda.Name),
+			Namespace:       erda.Namespace,
+			Labels:          ComposeDeclaredLabels(erda.Name),
+			OwnerReferences: references,
+		},
+		Data: map[string]string{
+			ServiceRegistryJSONKey: string(jsonData),
+			ServiceRegistryYAMLKey: string(yamlData),
+		},
+	}, nil
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+)
+
+const (
+	DefaultErdaRevisionHistoryLimit int32 = 10
+
+	RevisionOutcomeReady  = "Ready"
+	RevisionOutcomeFailed = "Failed"
+)
+
+// ComposeHash returns the short hash of the json of the object
+func ComposeHash(obj interface{}) string {
+	data, _ := json.Marshal(obj)
+	return fmt.Sprintf("%x", sha256.Sum256(data))[:16]
+}
+
+// ComposeRevisionName returns the name of the ControllerRevision of the Erda spec hash
+func ComposeRevisionName(erdaName, hash string) string {
+	return fmt.Sprintf("%s-%s", erdaName, hash)
+}
+
+// ComposeRevisionHistoryLimit returns the count of the spec revisions which are kept,
+// the current revision is always kept
+func ComposeRevisionHistoryLimit(erda *erdav1beta1.Erda) int {
+	if erda.Spec.RevisionHistoryLimit != nil {
+		if *erda.Spec.RevisionHistoryLimit < 1 {
+			return 1
+		}
+		return int(*erda.Spec.RevisionHistoryLimit)
+	}
+	return int(DefaultErdaRevisionHistoryLimit)
+}
+
+// ComposeControllerRevision composes the immutable revision of the Erda spec, the revision number
+// is increased and the applied time is refreshed when the spec is applied again
+func ComposeControllerRevision(erda *erdav1beta1.Erda, references []metav1.OwnerReference,
+	revision int64, now time.Time) (*appsv1.ControllerRevision, error) {
+	data, err := json.Marshal(erda.Spec)
+	if err != nil {
+		return nil, err
+	}
+	hash := ComposeHash(erda.Spec)
+	return &appsv1.ControllerRevision{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ComposeRevisionName(erda.Name, hash),
+			Namespace: erda.Namespace,
+			Labels: map[string]string{
+				erdav1beta1.ErdaOperatorLabel: "true",
+				erdav1beta1.ErdaNameLabel:     erda.Name,
+				erdav1beta1.ErdaRevisionLabel: hash,
+			},
+			Annotations: map[string]string{
+				erdav1beta1.AnnotationRevisionAppliedTime: now.UTC().Format(time.RFC3339),
+			},
+			OwnerReferences: references,
+		},
+		Data:     runtime.RawExtension{Raw: data},
+		Revision: revision,
+	}, nil
+}
+
+// ComposeErdaSpecFromRevision returns the Erda spec which is stored in the revision
+func ComposeErdaSpecFromRevision(revision *appsv1.ControllerRevision) (*erdav1beta1.ErdaSpec, error) {
+	spec := &erdav1beta1.ErdaSpec{}
+	if err := json.Unmarshal(revision.Data.Raw, spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// ComposeRevisionOutcome returns the outcome of the revision when its rollout finishes, it is Ready
+// when the Erda is ready, and Failed when the Erda fails or any rollout exceeds the progress deadline.
+// It is empty while the revision is being applied, hibernated or degraded by the unready pods
+func ComposeRevisionOutcome(phase erdav1beta1.PhaseType, observation PhaseObservation) string {
+	switch {
+	case phase == erdav1beta1.PhaseReady:
+		return RevisionOutcomeReady
+	case phase == erdav1beta1.PhaseFailed:
+		return RevisionOutcomeFailed
+	case phase == erdav1beta1.PhaseDegraded && len(observation.Failures) > 0:
+		return RevisionOutcomeFailed
+	}
+	return ""
+}
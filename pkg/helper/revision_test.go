@@ -0,0 +1,54 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"testing"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+)
+
+func TestComposeRevisionOutcome(t *testing.T) {
+	tests := []struct {
+		name        string
+		phase       erdav1beta1.PhaseType
+		observation PhaseObservation
+		want        string
+	}{
+		{name: "ready", phase: erdav1beta1.PhaseReady, want: RevisionOutcomeReady},
+		{name: "failed pre job", phase: erdav1beta1.PhaseFailed, want: RevisionOutcomeFailed},
+		{
+			name:        "rollout exceeds the progress deadline",
+			phase:       erdav1beta1.PhaseDegraded,
+			observation: PhaseObservation{Failures: []string{"component a ProgressDeadlineExceeded"}},
+			want:        RevisionOutcomeFailed,
+		},
+		{
+			name:        "degraded by the unready pods",
+			phase:       erdav1beta1.PhaseDegraded,
+			observation: PhaseObservation{Unready: []string{"component a unready"}},
+		},
+		{name: "deploying", phase: erdav1beta1.PhaseDeploying, observation: PhaseObservation{Progressing: true}},
+		{name: "upgrading", phase: erdav1beta1.PhaseUpgrading, observation: PhaseObservation{Progressing: true}},
+		{name: "hibernated", phase: erdav1beta1.PhaseHibernated, observation: PhaseObservation{Hibernated: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ComposeRevisionOutcome(tt.phase, tt.observation); got != tt.want {
+				t.Errorf("ComposeRevisionOutcome() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+)
+
+const deploymentProgressDeadlineExceeded = "ProgressDeadlineExceeded"
+
+// IsAutoRollbackEnabled reports whether the failed rollout of the component is rolled back
+func IsAutoRollbackEnabled(component *erdav1beta1.Component) bool {
+	return component.Rollout != nil && component.Rollout.AutoRollback
+}
+
+// ComposeWorkloadPodTemplate returns the pod template of the workload, it is nil for the other kinds
+func ComposeWorkloadPodTemplate(obj client.Object) *corev1.PodTemplateSpec {
+	switch v := obj.(type) {
+	case *appsv1.Deployment:
+		return &v.Spec.Template
+	case *appsv1.StatefulSet:
+		return &v.Spec.Template
+	case *appsv1.DaemonSet:
+		return &v.Spec.Template
+	}
+	return nil
+}
+
+// ComposePodTemplateHash returns the hash of the pod template which is composed from the component
+// or normalized by ComposePodTemplateSpecFromPodTemplate
+func ComposePodTemplateHash(template corev1.PodTemplateSpec) string {
+	return ComposeHash(template)
+}
+
+// ComposeLastKnownGoodHash returns the hash of the pod template which is recorded when the workload
+// was ready, the pod template itself is kept in the ControllerRevision named after the hash
+func ComposeLastKnownGoodHash(obj client.Object) string {
+	return obj.GetAnnotations()[erdav1beta1.AnnotationLastKnownGood]
+}
+
+// ComposeLastKnownGoodRevisionName returns the name of the ControllerRevision which keeps the last
+// known-good pod template of the workload
+func ComposeLastKnownGoodRevisionName(workloadName, hash string) string {
+	return fmt.Sprintf("%s-last-known-good-%s", workloadName, hash)
+}
+
+// ComposeLastKnownGoodRevision composes the ControllerRevision of the last known-good pod template of
+// the workload, it is owned by the workload and not labeled by the component, so the StatefulSet and
+// the DaemonSet controllers do not adopt it as their own history
+func ComposeLastKnownGoodRevision(obj client.Object, template corev1.PodTemplateSpec,
+	references []metav1.OwnerReference) (*appsv1.ControllerRevision, error) {
+	data, err := json.Marshal(template)
+	if err != nil {
+		return nil, err
+	}
+	hash := ComposePodTemplateHash(template)
+	return &appsv1.ControllerRevision{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ComposeLastKnownGoodRevisionName(obj.GetName(), hash),
+			Namespace: obj.GetNamespace(),
+			Labels: map[string]string{
+				erdav1beta1.ErdaOperatorLabel: "true",
+				erdav1beta1.ErdaRevisionLabel: hash,
+			},
+			OwnerReferences: references,
+		},
+		Data: runtime.RawExtension{Raw: data},
+	}, nil
+}
+
+// ComposePodTemplateFromRevision returns the pod template which is kept in the revision
+func ComposePodTemplateFromRevision(revision *appsv1.ControllerRevision) (*corev1.PodTemplateSpec, error) {
+	template := &corev1.PodTemplateSpec{}
+	if err := json.Unmarshal(revision.Data.Raw, template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// IsWorkloadRolledOut reports whether all the pods of the workload are updated and available
+func IsWorkloadRolledOut(obj client.Object) bool {
+	switch v := obj.(type) {
+	case *appsv1.Deployment:
+		replicas := int32(1)
+		if v.Spec.Replicas != nil {
+			replicas = *v.Spec.Replicas
+		}
+		return v.Status.ObservedGeneration >= v.Generation && v.Status.UpdatedReplicas == replicas &&
+			v.Status.AvailableReplicas == replicas && v.Status.UnavailableReplicas == 0
+	case *appsv1.StatefulSet:
+		replicas := int32(1)
+		if v.Spec.Replicas != nil {
+			replicas = *v.Spec.Replicas
+		}
+		return v.Status.ObservedGeneration >= v.Generation &&
+			v.Status.UpdatedReplicas >= composeStatefulSetUpdatedReplicas(v) && v.Status.ReadyReplicas == replicas
+	case *appsv1.DaemonSet:
+		return v.Status.ObservedGeneration >= v.Generation && v.Status.NumberUnavailable == 0 &&
+			v.Status.UpdatedNumberScheduled == v.Status.DesiredNumberScheduled &&
+			v.Status.NumberAvailable == v.Status.DesiredNumberScheduled
+	}
+	return false
+}
+
+// composeStatefulSetUpdatedReplicas returns the count of the pods to be updated, the pods
+// below the partition are not updated
+func composeStatefulSetUpdatedReplicas(statefulSet *appsv1.StatefulSet) int32 {
+	replicas, partition := int32(1), int32(0)
+	if statefulSet.Spec.Replicas != nil {
+		replicas = *statefulSet.Spec.Replicas
+	}
+	if rollingUpdate := statefulSet.Spec.UpdateStrategy.RollingUpdate; rollingUpdate != nil && rollingUpdate.Partition != nil {
+		partition = *rollingUpdate.Partition
+	}
+	if replicas < partition {
+		return 0
+	}
+	return replicas - partition
+}
+
+// ComposeRolloutAnnotations records the rollout of the new workload from the existing one, the
+// rollout time is reset when the pod template of the component changes, and the hash of the pod
+// template of the rolled out workload is recorded as the last known-good one
+func ComposeRolloutAnnotations(obj, newObj client.Object, now time.Time) {
+	annotations := obj.GetAnnotations()
+	newAnnotations := map[string]string{}
+	for key, value := range newObj.GetAnnotations() {
+		newAnnotations[key] = value
+	}
+
+	templateHash := ComposePodTemplateHash(*ComposeWorkloadPodTemplate(newObj))
+	newAnnotations[erdav1beta1.AnnotationTemplateHash] = templateHash
+	newAnnotations[erdav1beta1.AnnotationRolloutTime] = now.UTC().Format(time.RFC3339)
+	if annotations[erdav1beta1.AnnotationTemplateHash] == templateHash && annotations[erdav1beta1.AnnotationRolloutTime] != "" {
+		newAnnotations[erdav1beta1.AnnotationRolloutTime] = annotations[erdav1beta1.AnnotationRolloutTime]
+	}
+	// the rollback is kept until the component is changed
+	if annotations[erdav1beta1.AnnotationRollback] == templateHash {
+		newAnnotations[erdav1beta1.AnnotationRollback] = annotations[erdav1beta1.AnnotationRollback]
+		newAnnotations[erdav1beta1.AnnotationRollbackReason] = annotations[erdav1beta1.AnnotationRollbackReason]
+	}
+
+	if value, ok := annotations[erdav1beta1.AnnotationLastKnownGood]; ok {
+		newAnnotations[erdav1beta1.AnnotationLastKnownGood] = value
+	}
+	template := ComposeWorkloadPodTemplate(obj)
+	if template != nil && len(template.Spec.Containers) > 0 && IsWorkloadRolledOut(obj) {
+		newAnnotations[erdav1beta1.AnnotationLastKnownGood] =
+			ComposePodTemplateHash(ComposePodTemplateSpecFromPodTemplate(*template))
+	}
+	newObj.SetAnnotations(newAnnotations)
+}
+
+// IsRolledBack reports whether the workload is rolled back from the pod template of the new workload
+func IsRolledBack(obj, newObj client.Object) bool {
+	rollback := obj.GetAnnotations()[erdav1beta1.AnnotationRollback]
+	return rollback != "" && rollback == newObj.GetAnnotations()[erdav1beta1.AnnotationTemplateHash]
+}
+
+// IsRollingOut reports whether the workload runs a pod template other than the last known-good one
+func IsRollingOut(obj client.Object) bool {
+	template := ComposeWorkloadPodTemplate(obj)
+	if template == nil || len(template.Spec.Containers) == 0 {
+		return false
+	}
+	return ComposePodTemplateHash(ComposePodTemplateSpecFromPodTemplate(*template)) != ComposeLastKnownGoodHash(obj)
+}
+
+// ComposeWorkloadFailure returns the reason why the rollout of the workload fails, the Deployment
+// reports it by the progress deadline condition, the rollouts of the StatefulSet and the DaemonSet
+// are stalled if the pods are not updated and ready in the progress deadline
+func ComposeWorkloadFailure(obj client.Object, component *erdav1beta1.Component, now time.Time) string {
+	if obj == nil || !IsRollingOut(obj) {
+		return ""
+	}
+	if deployment, ok := obj.(*appsv1.Deployment); ok {
+		for _, condition := range deployment.Status.Conditions {
+			if condition.Type == appsv1.DeploymentProgressing && condition.Status == corev1.ConditionFalse &&
+				condition.Reason == deploymentProgressDeadlineExceeded {
+				return fmt.Sprintf("%s: %s", condition.Reason, condition.Message)
+			}
+		}
+		return ""
+	}
+
+	rolloutTime, err := time.Parse(time.RFC3339, obj.GetAnnotations()[erdav1beta1.AnnotationRolloutTime])
+	if err != nil || IsWorkloadRolledOut(obj) {
+		return ""
+	}
+	deadline := time.Duration(*composeProgressDeadlineSeconds(component)) * time.Second
+	if now.Sub(rolloutTime) < deadline {
+		return ""
+	}
+	return fmt.Sprintf("RolloutStalled: %s has not been rolled out in %s", obj.GetName(), deadline)
+}
+
+// ComposeWorkloadDeadline returns the progress deadline of the rolling out StatefulSet or DaemonSet,
+// it is zero for the Deployment which reports the exceeded deadline by its status
+func ComposeWorkloadDeadline(obj client.Object, component *erdav1beta1.Component) time.Time {
+	if obj == nil || !IsRollingOut(obj) || IsWorkloadRolledOut(obj) {
+		return time.Time{}
+	}
+	if _, ok := obj.(*appsv1.Deployment); ok {
+		return time.Time{}
+	}
+	rolloutTime, err := time.Parse(time.RFC3339, obj.GetAnnotations()[erdav1beta1.AnnotationRolloutTime])
+	if err != nil {
+		return time.Time{}
+	}
+	return rolloutTime.Add(time.Duration(*composeProgressDeadlineSeconds(component)) * time.Second)
+}
+
+// ComposeRollbackReason returns the failure reason of the rollout which the workload is rolled back from
+func ComposeRollbackReason(obj client.Object) string {
+	if obj == nil || obj.GetAnnotations()[erdav1beta1.AnnotationRollback] == "" {
+		return ""
+	}
+	return fmt.Sprintf("rolled back to the last known-good template: %s",
+		obj.GetAnnotations()[erdav1beta1.AnnotationRollbackReason])
+}
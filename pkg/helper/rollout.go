@@ -0,0 +1,108 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+	"github.com/erda-project/erda-operator/pkg/utils"
+)
+
+// the defaults of Kubernetes, they are filled explicitly to keep the diff stable
+const (
+	DefaultRevisionHistoryLimit    int32 = 3
+	DefaultProgressDeadlineSeconds int32 = 600
+)
+
+var (
+	defaultDeploymentMaxSurge       = intstr.FromString("25%")
+	defaultDeploymentMaxUnavailable = intstr.FromString("25%")
+	defaultDaemonSetMaxUnavailable  = intstr.FromInt(1)
+)
+
+func composeRollout(component *erdav1beta1.Component) erdav1beta1.Rollout {
+	if component.Rollout == nil {
+		return erdav1beta1.Rollout{}
+	}
+	return *component.Rollout
+}
+
+func composeRevisionHistoryLimit(component *erdav1beta1.Component) *int32 {
+	if rollout := composeRollout(component); rollout.RevisionHistoryLimit != nil {
+		return rollout.RevisionHistoryLimit
+	}
+	return utils.ConvertInt32ToPointInt32(DefaultRevisionHistoryLimit)
+}
+
+func composeProgressDeadlineSeconds(component *erdav1beta1.Component) *int32 {
+	if rollout := composeRollout(component); rollout.ProgressDeadlineSeconds != nil {
+		return rollout.ProgressDeadlineSeconds
+	}
+	return utils.ConvertInt32ToPointInt32(DefaultProgressDeadlineSeconds)
+}
+
+func composeDeploymentStrategy(component *erdav1beta1.Component) appsv1.DeploymentStrategy {
+	rollout := composeRollout(component)
+	maxSurge, maxUnavailable := defaultDeploymentMaxSurge, defaultDeploymentMaxUnavailable
+	if rollout.MaxSurge != nil {
+		maxSurge = *rollout.MaxSurge
+	}
+	if rollout.MaxUnavailable != nil {
+		maxUnavailable = *rollout.MaxUnavailable
+	}
+	return appsv1.DeploymentStrategy{
+		Type: appsv1.RollingUpdateDeploymentStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateDeployment{
+			MaxSurge:       &maxSurge,
+			MaxUnavailable: &maxUnavailable,
+		},
+	}
+}
+
+func composeStatefulSetUpdateStrategy(component *erdav1beta1.Component) appsv1.StatefulSetUpdateStrategy {
+	partition := utils.ConvertInt32ToPointInt32(0)
+	if rollout := composeRollout(component); rollout.Partition != nil {
+		partition = rollout.Partition
+	}
+	return appsv1.StatefulSetUpdateStrategy{
+		Type: appsv1.RollingUpdateStatefulSetStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateStatefulSetStrategy{
+			Partition: partition,
+		},
+	}
+}
+
+func composePodManagementPolicy(component *erdav1beta1.Component) appsv1.PodManagementPolicyType {
+	if rollout := composeRollout(component); rollout.PodManagementPolicy != "" {
+		return rollout.PodManagementPolicy
+	}
+	return appsv1.OrderedReadyPodManagement
+}
+
+// composeDaemonSetUpdateStrategy updates one node at a time by default
+func composeDaemonSetUpdateStrategy(component *erdav1beta1.Component) appsv1.DaemonSetUpdateStrategy {
+	maxUnavailable := defaultDaemonSetMaxUnavailable
+	if rollout := composeRollout(component); rollout.MaxUnavailable != nil {
+		maxUnavailable = *rollout.MaxUnavailable
+	}
+	return appsv1.DaemonSetUpdateStrategy{
+		Type: appsv1.RollingUpdateDaemonSetStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateDaemonSet{
+			MaxUnavailable: &maxUnavailable,
+		},
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"fmt"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+	"github.com/erda-project/erda-operator/pkg/utils"
+)
+
+const (
+	GatewayAPIGroup = "gateway.networking.k8s.io"
+	HTTPRouteKind   = "HTTPRoute"
+	GRPCRouteKind   = "GRPCRoute"
+)
+
+var (
+	HTTPRouteGVK = schema.GroupVersionKind{Group: GatewayAPIGroup, Version: "v1", Kind: HTTPRouteKind}
+	GRPCRouteGVK = schema.GroupVersionKind{Group: GatewayAPIGroup, Version: "v1", Kind: GRPCRouteKind}
+)
+
+// GatewayRef is the parent Gateway which the generated routes attach to
+type GatewayRef struct {
+	Namespace string
+	Name      string
+}
+
+// ParseGatewayRef parses the gateway reference in format namespace/name,
+// the namespace is optional
+func ParseGatewayRef(ref string) GatewayRef {
+	if i := strings.Index(ref, "/"); i >= 0 {
+		return GatewayRef{Namespace: ref[:i], Name: ref[i+1:]}
+	}
+	return GatewayRef{Name: ref}
+}
+
+// ComposeRoutingMode returns the routing mode of the component,
+// the component annotation overrides the operator default
+func ComposeRoutingMode(component *erdav1beta1.Component, defaultMode erdav1beta1.RoutingMode) erdav1beta1.RoutingMode {
+	if v := component.Annotations[erdav1beta1.AnnotationRoutingMode]; v != "" {
+		return erdav1beta1.RoutingMode(v)
+	}
+	if defaultMode == "" {
+		return erdav1beta1.RoutingModeIngress
+	}
+	return defaultMode
+}
+
+// ComposeGatewayRef returns the parent Gateway of the component routes,
+// the component annotation overrides the operator default
+func ComposeGatewayRef(component *erdav1beta1.Component, defaultGateway GatewayRef) GatewayRef {
+	gateway := defaultGateway
+	if v := component.Annotations[erdav1beta1.AnnotationGateway]; v != "" {
+		gateway = ParseGatewayRef(v)
+	}
+	if gateway.Namespace == "" {
+		gateway.Namespace = component.Namespace
+	}
+	return gateway
+}
+
+// ComposeGatewayRoutes composes the HTTPRoutes and GRPCRoutes of the component,
+// every domain is rendered as one route, GRPC protocol ports are rendered as GRPCRoutes
+// with one rule per domain
+func ComposeGatewayRoutes(component *erdav1beta1.Component, references []metav1.OwnerReference,
+	gateway GatewayRef) []*unstructured.Unstructured {
+	httpRules, httpDomains := map[string][]interface{}{}, []string{}
+	grpcRules, grpcDomains := map[string][]interface{}{}, []string{}
+	pathVisited := make(map[string]bool)
+
+	for _, sd := range component.Network.ServiceDiscovery {
+		if sd.Domain == "" {
+			continue
+		}
+		// the GRPC rule without matches catches all the methods of the domain, so only the
+		// first GRPC port of the domain is routed like the first port of the same HTTP path
+		if strings.ToUpper(sd.Protocol) == GRPCProtocolType {
+			if _, ok := grpcRules[sd.Domain]; ok {
+				continue
+			}
+			grpcDomains = append(grpcDomains, sd.Domain)
+			grpcRules[sd.Domain] = []interface{}{
+				map[string]interface{}{
+					"backendRefs": composeRouteBackendRefs(component.Name, sd.Port),
+				},
+			}
+			continue
+		}
+		pathKey := fmt.Sprintf("%s%s", sd.Domain, ComposeIngressPath(sd))
+		if pathVisited[pathKey] {
+			continue
+		}
+		pathVisited[pathKey] = true
+		if _, ok := httpRules[sd.Domain]; !ok {
+			httpDomains = append(httpDomains, sd.Domain)
+		}
+		httpRules[sd.Domain] = append(httpRules[sd.Domain], map[string]interface{}{
+			"matches":     composeRoutePathMatches(ComposeIngressPathType(sd), ComposeIngressPath(sd)),
+			"backendRefs": composeRouteBackendRefs(component.Name, sd.Port),
+		})
+	}
+
+	// microservice endpoints route to the default port of the component
+	if component.Network.Microservices != nil && len(component.Network.ServiceDiscovery) > 0 {
+		port := component.Network.ServiceDiscovery[0].Port
+		for _, endpoint := range component.Network.Microservices.Endpoints {
+			if endpoint.Domain == "" {
+				continue
+			}
+			path := endpoint.Path
+			if path == "" {
+				path = "/"
+			}
+			pathKey := fmt.Sprintf("%s%s", endpoint.Domain, path)
+			if pathVisited[pathKey] {
+				continue
+			}
+			pathVisited[pathKey] = true
+			if _, ok := httpRules[endpoint.Domain]; !ok {
+				httpDomains = append(httpDomains, endpoint.Domain)
+			}
+			rule := map[string]interface{}{
+				"matches":     composeRoutePathMatches(networkingv1.PathTypePrefix, path),
+				"backendRefs": composeRouteBackendRefs(component.Name, port),
+			}
+			if endpoint.BackendPath != "" && endpoint.BackendPath != path {
+				rule["filters"] = []interface{}{
+					map[string]interface{}{
+						"type": "URLRewrite",
+						"urlRewrite": map[string]interface{}{
+							"path": map[string]interface{}{
+								"type":               "ReplacePrefixMatch",
+								"replacePrefixMatch": endpoint.BackendPath,
+							},
+						},
+					},
+				}
+			}
+			httpRules[endpoint.Domain] = append(httpRules[endpoint.Domain], rule)
+		}
+	}
+
+	routes := make([]*unstructured.Unstructured, 0, len(httpDomains)+len(grpcDomains))
+	for _, domain := range httpDomains {
+		routes = append(routes, composeRoute(component, references, HTTPRouteGVK,
+			ComposeRouteName(component.Name, HTTPRouteKind, domain), gateway, domain, httpRules[domain]))
+	}
+	for _, domain := range grpcDomains {
+		routes = append(routes, composeRoute(component, references, GRPCRouteGVK,
+			ComposeRouteName(component.Name, GRPCRouteKind, domain), gateway, domain, grpcRules[domain]))
+	}
+	return routes
+}
+
+// ComposeRouteName returns the route name of the component by route kind and domain, so the
+// routes of the other domains are kept when the domains are reordered or removed, the domain
+// which is not a valid name is replaced by its hash
+func ComposeRouteName(componentName, kind, domain string) string {
+	prefix := fmt.Sprintf("%s-%s-", componentName, strings.ToLower(strings.TrimSuffix(kind, "Route")))
+	name := prefix + strings.Replace(strings.ToLower(domain), "*", "wildcard", 1)
+	if len(validation.IsDNS1123Subdomain(name)) > 0 {
+		return prefix + ComposeHash(domain)
+	}
+	return name
+}
+
+func composeRoute(component *erdav1beta1.Component, references []metav1.OwnerReference,
+	gvk schema.GroupVersionKind, name string, gateway GatewayRef, domain string,
+	rules []interface{}) *unstructured.Unstructured {
+	objectMeta := utils.ComposeObjectMetadataFromComponent(component, references)
+
+	route := &unstructured.Unstructured{}
+	route.SetGroupVersionKind(gvk)
+	route.SetName(name)
+	route.SetNamespace(objectMeta.Namespace)
+	route.SetLabels(objectMeta.Labels)
+	route.SetOwnerReferences(objectMeta.OwnerReferences)
+	route.Object["spec"] = map[string]interface{}{
+		"parentRefs": []interface{}{
+			map[string]interface{}{
+				"group":     GatewayAPIGroup,
+				"kind":      "Gateway",
+				"namespace": gateway.Namespace,
+				"name":      gateway.Name,
+			},
+		},
+		"hostnames": []interface{}{domain},
+		"rules":     rules,
+	}
+	return route
+}
+
+// composeRoutePathMatches converts the ingress path type to route path match type,
+// ImplementationSpecific is treated as PathPrefix
+func composeRoutePathMatches(pathType networkingv1.PathType, path string) []interface{} {
+	matchType := "PathPrefix"
+	if pathType == networkingv1.PathTypeExact {
+		matchType = "Exact"
+	}
+	if path == "" {
+		path = "/"
+	}
+	return []interface{}{
+		map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":  matchType,
+				"value": path,
+			},
+		},
+	}
+}
+
+func composeRouteBackendRefs(serviceName string, port int32) []interface{} {
+	return []interface{}{
+		map[string]interface{}{
+			"group":  "",
+			"kind":   "Service",
+			"name":   serviceName,
+			"port":   int64(port),
+			"weight": int64(1),
+		},
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"testing"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+)
+
+func TestComposeRouteName(t *testing.T) {
+	tests := []struct {
+		name   string
+		kind   string
+		domain string
+		want   string
+	}{
+		{name: "http route", kind: HTTPRouteKind, domain: "erda.example.com", want: "web-http-erda.example.com"},
+		{name: "grpc route", kind: GRPCRouteKind, domain: "erda.example.com", want: "web-grpc-erda.example.com"},
+		{name: "upper case domain", kind: HTTPRouteKind, domain: "Erda.Example.com", want: "web-http-erda.example.com"},
+		{name: "wildcard domain", kind: HTTPRouteKind, domain: "*.example.com", want: "web-http-wildcard.example.com"},
+		{name: "invalid name is hashed", kind: HTTPRouteKind, domain: "erda_web.example.com",
+			want: "web-http-" + ComposeHash("erda_web.example.com")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ComposeRouteName("web", tt.kind, tt.domain); got != tt.want {
+				t.Errorf("ComposeRouteName() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComposeGatewayRoutes(t *testing.T) {
+	tests := []struct {
+		name      string
+		sds       []erdav1beta1.ServiceDiscovery
+		wantRules map[string]int
+		wantPorts map[string]int64
+	}{
+		{
+			name: "one route per domain and kind",
+			sds: []erdav1beta1.ServiceDiscovery{
+				{Port: 8080, Protocol: "HTTP", Domain: "erda.example.com"},
+				{Port: 8081, Protocol: "HTTP", Domain: "erda.example.com", Path: "/api"},
+				{Port: 9090, Protocol: "GRPC", Domain: "erda.example.com"},
+				{Port: 8080, Protocol: "HTTP", Domain: "*.example.com"},
+				{Port: 8082, Protocol: "TCP"},
+			},
+			wantRules: map[string]int{
+				"web-http-erda.example.com":     2,
+				"web-http-wildcard.example.com": 1,
+				"web-grpc-erda.example.com":     1,
+			},
+			wantPorts: map[string]int64{
+				"web-http-erda.example.com":     8080,
+				"web-http-wildcard.example.com": 8080,
+				"web-grpc-erda.example.com":     9090,
+			},
+		},
+		{
+			name: "the first port of the same path is routed",
+			sds: []erdav1beta1.ServiceDiscovery{
+				{Port: 8080, Protocol: "HTTP", Domain: "erda.example.com", Path: "/api"},
+				{Port: 8081, Protocol: "HTTPS", Domain: "erda.example.com", Path: "/api"},
+			},
+			wantRules: map[string]int{"web-http-erda.example.com": 1},
+			wantPorts: map[string]int64{"web-http-erda.example.com": 8080},
+		},
+		{
+			name: "the first grpc port of the domain is routed",
+			sds: []erdav1beta1.ServiceDiscovery{
+				{Port: 9090, Protocol: "GRPC", Domain: "erda.example.com"},
+				{Port: 9091, Protocol: "grpc", Domain: "erda.example.com"},
+				{Port: 9092, Protocol: "GRPC", Domain: "grpc.example.com"},
+			},
+			wantRules: map[string]int{
+				"web-grpc-erda.example.com": 1,
+				"web-grpc-grpc.example.com": 1,
+			},
+			wantPorts: map[string]int64{
+				"web-grpc-erda.example.com": 9090,
+				"web-grpc-grpc.example.com": 9092,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			component := &erdav1beta1.Component{
+				Metadata:      erdav1beta1.Metadata{Name: "web", Namespace: "erda"},
+				ComponentSpec: erdav1beta1.ComponentSpec{Network: &erdav1beta1.Network{ServiceDiscovery: tt.sds}},
+			}
+			routes := ComposeGatewayRoutes(component, nil, GatewayRef{Namespace: "gateway", Name: "erda"})
+			if len(routes) != len(tt.wantRules) {
+				t.Fatalf("routes = %d, want %d", len(routes), len(tt.wantRules))
+			}
+			for _, route := range routes {
+				wantRules, ok := tt.wantRules[route.GetName()]
+				if !ok {
+					t.Errorf("unexpected route %s", route.GetName())
+					continue
+				}
+				spec := route.Object["spec"].(map[string]interface{})
+				rules := spec["rules"].([]interface{})
+				if len(rules) != wantRules {
+					t.Errorf("rules of route %s = %d, want %d", route.GetName(), len(rules), wantRules)
+				}
+				backend := rules[0].(map[string]interface{})["backendRefs"].([]interface{})[0].(map[string]interface{})
+				if backend["port"] != tt.wantPorts[route.GetName()] {
+					t.Errorf("port of route %s = %v, want %d", route.GetName(), backend["port"], tt.wantPorts[route.GetName()])
+				}
+			}
+		})
+	}
+}
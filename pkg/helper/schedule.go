@@ -0,0 +1,105 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+)
+
+// ScheduleState is the scaling state of the Erda at a moment
+type ScheduleState struct {
+	// Hibernated is set by the hibernate annotation or an active hibernate schedule
+	Hibernated bool
+	// Replicas is the replicas overrides of the active schedules by component name
+	Replicas map[string]int32
+	// NextTransition is the earliest time when a schedule starts or ends,
+	// it is zero if the Erda has no schedule
+	NextTransition time.Time
+}
+
+// ComposeScheduleState evaluates the hibernate annotation and the schedules of the Erda at now,
+// the replicas overrides of the later schedules take precedence
+func ComposeScheduleState(erda *erdav1beta1.Erda, now time.Time) (ScheduleState, error) {
+	state := ScheduleState{
+		Replicas: make(map[string]int32),
+	}
+	if hibernated, err := strconv.ParseBool(erda.Annotations[erdav1beta1.AnnotationHibernate]); err == nil {
+		state.Hibernated = hibernated
+	}
+	if erda.Spec == nil {
+		return state, nil
+	}
+
+	for _, schedule := range erda.Spec.Schedules {
+		location := now.Location()
+		if schedule.TimeZone != "" {
+			var err error
+			if location, err = time.LoadLocation(schedule.TimeZone); err != nil {
+				return state, errors.Wrapf(err, "invalid time zone of schedule %s", schedule.Name)
+			}
+		}
+		start, err := cron.ParseStandard(schedule.Start)
+		if err != nil {
+			return state, errors.Wrapf(err, "invalid start of schedule %s", schedule.Name)
+		}
+		end, err := cron.ParseStandard(schedule.End)
+		if err != nil {
+			return state, errors.Wrapf(err, "invalid end of schedule %s", schedule.Name)
+		}
+
+		localNow := now.In(location)
+		nextStart, nextEnd := start.Next(localNow), end.Next(localNow)
+		for _, next := range []time.Time{nextStart, nextEnd} {
+			if !next.IsZero() && (state.NextTransition.IsZero() || next.Before(state.NextTransition)) {
+				state.NextTransition = next
+			}
+		}
+		// the schedule is active when it will end before it starts again
+		if nextStart.IsZero() || nextEnd.IsZero() || !nextEnd.Before(nextStart) {
+			continue
+		}
+		if schedule.Hibernate {
+			state.Hibernated = true
+		}
+		for name, replicas := range schedule.Replicas {
+			state.Replicas[name] = replicas
+		}
+	}
+	return state, nil
+}
+
+// ApplyScheduleState overrides the replicas of the component by the schedule state,
+// the PerNode component is not scaled since the DaemonSet has no replicas
+func ApplyScheduleState(component *erdav1beta1.Component, state ScheduleState) {
+	if component.WorkLoad == erdav1beta1.PerNode {
+		return
+	}
+	replicas, ok := state.Replicas[component.Name]
+	if state.Hibernated {
+		replicas, ok = 0, true
+	}
+	if !ok {
+		return
+	}
+	component.Replicas = &replicas
+	// the autoscaler would fight with the overridden replicas
+	component.Autoscaling = nil
+}
@@ -20,6 +20,7 @@ import (
 
 	"github.com/gogo/protobuf/sortkeys"
 
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -43,15 +44,91 @@ func ComposeKubernetesService(
 	k8sService := &corev1.Service{
 		Spec: corev1.ServiceSpec{
 			SessionAffinity: corev1.ServiceAffinityNone,
-			Selector: utils.AppendLabels(component.Labels, map[string]string{
-				erdav1beta1.ErdaComponentLabel: component.Name,
-			}),
-			Type: corev1.ServiceTypeClusterIP,
+			Selector:        ComposeComponentSelector(component),
+			Type:            corev1.ServiceTypeClusterIP,
 		},
 	}
 
 	k8sService.ObjectMeta = utils.ComposeObjectMetadataFromComponent(component, references)
+	k8sService.Spec.Ports = composeServicePorts(component)
+	return k8sService
+}
+
+// ComposeHeadlessService returns the headless Service which governs the StatefulSet for the stable
+// pod DNS, the client Service of the component is kept for the load balanced cluster ip
+func ComposeHeadlessService(component *erdav1beta1.Component,
+	references []metav1.OwnerReference) *corev1.Service {
+	if component.WorkLoad != erdav1beta1.Stateful {
+		return nil
+	}
+	k8sService := &corev1.Service{
+		Spec: corev1.ServiceSpec{
+			ClusterIP:                corev1.ClusterIPNone,
+			Selector:                 ComposeComponentSelector(component),
+			Type:                     corev1.ServiceTypeClusterIP,
+			PublishNotReadyAddresses: true,
+			Ports:                    composeServicePorts(component),
+		},
+	}
+	k8sService.ObjectMeta = utils.ComposeObjectMetadataFromComponent(component, references)
+	k8sService.Name = ComposeHeadlessServiceName(component.Name)
+	return k8sService
+}
+
+// ComposeHeadlessServiceName returns the name of the headless Service which governs the StatefulSet
+func ComposeHeadlessServiceName(componentName string) string {
+	return fmt.Sprintf("%s-headless", componentName)
+}
+
+// ComposeComponentSelector returns the labels which select all pods of the component
+func ComposeComponentSelector(component *erdav1beta1.Component) map[string]string {
+	return utils.AppendLabels(utils.MergeMap(component.Labels, nil), map[string]string{
+		erdav1beta1.ErdaComponentLabel: component.Name,
+	})
+}
+
+// ComposeOrdinalServices returns a Service for every pod of the Stateful component,
+// the not ready addresses are published for the members to discover each other when bootstrapping
+func ComposeOrdinalServices(component *erdav1beta1.Component,
+	references []metav1.OwnerReference) []*corev1.Service {
+	if component.WorkLoad != erdav1beta1.Stateful || !component.Network.OrdinalServices {
+		return nil
+	}
+	replicas := int32(1)
+	if component.Replicas != nil {
+		replicas = *component.Replicas
+	}
+	services := make([]*corev1.Service, 0, replicas)
+	for ordinal := int32(0); ordinal < replicas; ordinal++ {
+		podName := ComposeOrdinalServiceName(component.Name, ordinal)
+		k8sService := &corev1.Service{
+			Spec: corev1.ServiceSpec{
+				SessionAffinity: corev1.ServiceAffinityNone,
+				Selector: utils.AppendLabels(utils.MergeMap(component.Labels, nil), map[string]string{
+					erdav1beta1.ErdaComponentLabel: component.Name,
+					appsv1.StatefulSetPodNameLabel: podName,
+				}),
+				Type:                     corev1.ServiceTypeClusterIP,
+				PublishNotReadyAddresses: true,
+				Ports:                    composeServicePorts(component),
+			},
+		}
+		k8sService.ObjectMeta = utils.ComposeObjectMetadataFromComponent(component, references)
+		k8sService.Name = podName
+		k8sService.Labels = utils.AppendLabels(utils.MergeMap(k8sService.Labels, nil), map[string]string{
+			erdav1beta1.ErdaOrdinalLabel: fmt.Sprintf("%d", ordinal),
+		})
+		services = append(services, k8sService)
+	}
+	return services
+}
+
+// ComposeOrdinalServiceName returns the service name of the pod ordinal, it is same as the pod name
+func ComposeOrdinalServiceName(componentName string, ordinal int32) string {
+	return fmt.Sprintf("%s-%d", componentName, ordinal)
+}
 
+func composeServicePorts(component *erdav1beta1.Component) []corev1.ServicePort {
 	servicePorts := []corev1.ServicePort{}
 
 	sdMap := map[int32]erdav1beta1.ServiceDiscovery{}
@@ -68,13 +145,75 @@ func ComposeKubernetesService(
 		servicePort := corev1.ServicePort{}
 		servicePort.Port = sdMap[key].Port
 		servicePort.Protocol = GetKubernetesProtocol(sdMap[key].Protocol)
-		servicePort.Name = fmt.Sprintf("%s-%d",
-			strings.ToLower(string(servicePort.Protocol)), servicePort.Port)
+		servicePort.AppProtocol = GetAppProtocol(sdMap[key].Protocol)
+		servicePort.Name = ComposeServicePortName(sdMap[key].Protocol, servicePort.Port)
 		servicePort.TargetPort = intstr.FromInt(int(sdMap[key].Port))
 		servicePorts = append(servicePorts, servicePort)
 	}
-	k8sService.Spec.Ports = servicePorts
-	return k8sService
+	return servicePorts
+}
+
+// ComposeExternalServices returns the NodePort and LoadBalancer services
+// which contain the exposed ports of the component
+func ComposeExternalServices(component *erdav1beta1.Component,
+	references []metav1.OwnerReference) []*corev1.Service {
+	services := make([]*corev1.Service, 0)
+	for _, exposeType := range []erdav1beta1.ExposeType{erdav1beta1.ExposeNodePort, erdav1beta1.ExposeLoadBalancer} {
+		k8sService := &corev1.Service{
+			Spec: corev1.ServiceSpec{
+				SessionAffinity: corev1.ServiceAffinityNone,
+				Selector: utils.AppendLabels(utils.MergeMap(component.Labels, nil), map[string]string{
+					erdav1beta1.ErdaComponentLabel: component.Name,
+				}),
+				Type: corev1.ServiceType(exposeType),
+			},
+		}
+		k8sService.ObjectMeta = utils.ComposeObjectMetadataFromComponent(component, references)
+		k8sService.Name = ComposeExternalServiceName(component.Name, exposeType)
+
+		// the ports are unique by the port and the protocol, and the source ranges are unique
+		portVisited := make(map[string]bool)
+		rangeVisited := make(map[string]bool)
+		for _, sd := range component.Network.ServiceDiscovery {
+			if sd.Expose == nil || sd.Expose.Type != exposeType {
+				continue
+			}
+			servicePort := corev1.ServicePort{}
+			servicePort.Port = sd.Port
+			if exposeType == erdav1beta1.ExposeLoadBalancer && sd.Expose.Port != 0 {
+				servicePort.Port = sd.Expose.Port
+			}
+			servicePort.Protocol = GetKubernetesProtocol(sd.Protocol)
+			servicePort.AppProtocol = GetAppProtocol(sd.Protocol)
+			servicePort.Name = ComposeServicePortName(sd.Protocol, servicePort.Port)
+			servicePort.TargetPort = intstr.FromInt(int(sd.Port))
+			servicePort.NodePort = sd.Expose.NodePort
+			portKey := fmt.Sprintf("%d/%s", servicePort.Port, servicePort.Protocol)
+			if !portVisited[portKey] {
+				portVisited[portKey] = true
+				k8sService.Spec.Ports = append(k8sService.Spec.Ports, servicePort)
+			}
+			if exposeType != erdav1beta1.ExposeLoadBalancer {
+				continue
+			}
+			for _, sourceRange := range sd.Expose.SourceRanges {
+				if !rangeVisited[sourceRange] {
+					rangeVisited[sourceRange] = true
+					k8sService.Spec.LoadBalancerSourceRanges = append(k8sService.Spec.LoadBalancerSourceRanges,
+						sourceRange)
+				}
+			}
+		}
+		if len(k8sService.Spec.Ports) > 0 {
+			services = append(services, k8sService)
+		}
+	}
+	return services
+}
+
+// ComposeExternalServiceName returns the name of the external service by expose type
+func ComposeExternalServiceName(componentName string, exposeType erdav1beta1.ExposeType) string {
+	return fmt.Sprintf("%s-%s", componentName, strings.ToLower(string(exposeType)))
 }
 
 func GetKubernetesProtocol(protocol string) corev1.Protocol {
@@ -88,19 +227,24 @@ func GetKubernetesProtocol(protocol string) corev1.Protocol {
 	}
 }
 
-func ComposeKubernetesServiceSpecFromK8sService(service *corev1.Service) corev1.ServiceSpec {
-	k8sServiceSpec := corev1.ServiceSpec{
-		SessionAffinity: corev1.ServiceAffinityNone,
-		Selector:        service.Spec.Selector,
-		Type:            service.Spec.Type,
+// GetAppProtocol returns the application protocol of the service port,
+// it is nil for the plain TCP and UDP protocol
+func GetAppProtocol(protocol string) *string {
+	switch strings.ToUpper(protocol) {
+	case HTTPProtocolType, HTTPSProtocolType, GRPCProtocolType:
+		appProtocol := strings.ToLower(protocol)
+		return &appProtocol
+	default:
+		return nil
 	}
-	for _, port := range service.Spec.Ports {
-		k8sServiceSpec.Ports = append(k8sServiceSpec.Ports, corev1.ServicePort{
-			Name:       port.Name,
-			Protocol:   port.Protocol,
-			Port:       port.Port,
-			TargetPort: port.TargetPort,
-		})
+}
+
+// ComposeServicePortName names the service port as <protocol>-<port>,
+// the application protocol is used as prefix for the meshes to detect it
+func ComposeServicePortName(protocol string, port int32) string {
+	prefix := strings.ToLower(string(GetKubernetesProtocol(protocol)))
+	if appProtocol := GetAppProtocol(protocol); appProtocol != nil {
+		prefix = *appProtocol
 	}
-	return k8sServiceSpec
+	return fmt.Sprintf("%s-%d", prefix, port)
 }
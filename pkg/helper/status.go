@@ -0,0 +1,111 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+)
+
+// the waiting reasons of the containers which are starting normally
+var startingReasons = map[string]bool{
+	"ContainerCreating": true,
+	"PodInitializing":   true,
+}
+
+// ComposeWorkloadReplicas returns the desired and the ready replicas of the workload
+func ComposeWorkloadReplicas(obj client.Object) (int32, int32) {
+	switch v := obj.(type) {
+	case *appsv1.Deployment:
+		if v.Spec.Replicas == nil {
+			return 1, v.Status.ReadyReplicas
+		}
+		return *v.Spec.Replicas, v.Status.ReadyReplicas
+	case *appsv1.StatefulSet:
+		if v.Spec.Replicas == nil {
+			return 1, v.Status.ReadyReplicas
+		}
+		return *v.Spec.Replicas, v.Status.ReadyReplicas
+	case *appsv1.DaemonSet:
+		return v.Status.DesiredNumberScheduled, v.Status.NumberReady
+	}
+	return 0, 0
+}
+
+// composeContainerState returns the reason and the message of the unhealthy container,
+// the last termination is reported for the container which is backing off
+func composeContainerState(status corev1.ContainerStatus) (string, string) {
+	switch {
+	case status.State.Waiting != nil && !startingReasons[status.State.Waiting.Reason]:
+		message := status.State.Waiting.Message
+		if last := status.LastTerminationState.Terminated; last != nil {
+			message = fmt.Sprintf("%s, last terminated with %s (exit code %d)", message, last.Reason, last.ExitCode)
+		}
+		return status.State.Waiting.Reason, message
+	case status.State.Terminated != nil && status.State.Terminated.ExitCode != 0:
+		return status.State.Terminated.Reason, status.State.Terminated.Message
+	}
+	return "", ""
+}
+
+// ComposePodsStatus fills the replicas, the image, the restarts and the dominant reason of
+// the unhealthy containers into the component status from the workload and its pods
+func ComposePodsStatus(status *erdav1beta1.ComponentStatus, obj client.Object, pods []corev1.Pod) {
+	status.Replicas, status.ReadyReplicas = ComposeWorkloadReplicas(obj)
+	if template := ComposeWorkloadPodTemplate(obj); template != nil && len(template.Spec.Containers) > 0 {
+		status.Image = template.Spec.Containers[0].Image
+	}
+
+	counts, messages := map[string]int{}, map[string]string{}
+	for _, pod := range pods {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.DeletionTimestamp != nil {
+			continue
+		}
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse {
+				counts[condition.Reason]++
+				if _, ok := messages[condition.Reason]; !ok {
+					messages[condition.Reason] = condition.Message
+				}
+			}
+		}
+		containerStatuses := append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...)
+		for _, containerStatus := range containerStatuses {
+			status.Restarts += containerStatus.RestartCount
+			reason, message := composeContainerState(containerStatus)
+			if reason == "" {
+				continue
+			}
+			counts[reason]++
+			if _, ok := messages[reason]; !ok {
+				messages[reason] = message
+			}
+		}
+	}
+
+	// the most frequent reason is dominant, the reasons with the same count are ordered by name
+	for reason, count := range counts {
+		dominant := counts[status.ContainerReason]
+		if count > dominant || (count == dominant && reason < status.ContainerReason) {
+			status.ContainerReason = reason
+		}
+	}
+	status.ContainerMessage = messages[status.ContainerReason]
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+)
+
+func TestComposePodsStatus(t *testing.T) {
+	waiting := func(reason, message string, restarts int32) corev1.ContainerStatus {
+		return corev1.ContainerStatus{
+			RestartCount: restarts,
+			State: corev1.ContainerState{
+				Waiting: &corev1.ContainerStateWaiting{Reason: reason, Message: message},
+			},
+		}
+	}
+	pod := func(statuses ...corev1.ContainerStatus) corev1.Pod {
+		return corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodRunning, ContainerStatuses: statuses}}
+	}
+	backingOff := waiting("CrashLoopBackOff", "back-off restarting", 3)
+	backingOff.LastTerminationState.Terminated = &corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 1}
+	unschedulable := corev1.Pod{Status: corev1.PodStatus{
+		Phase: corev1.PodPending,
+		Conditions: []corev1.PodCondition{{
+			Type:    corev1.PodScheduled,
+			Status:  corev1.ConditionFalse,
+			Reason:  corev1.PodReasonUnschedulable,
+			Message: "0/3 nodes are available",
+		}},
+	}}
+	succeeded := pod(waiting("ImagePullBackOff", "", 0))
+	succeeded.Status.Phase = corev1.PodSucceeded
+	deleting := pod(waiting("ImagePullBackOff", "", 0))
+	deleting.DeletionTimestamp = &metav1.Time{}
+
+	tests := []struct {
+		name         string
+		pods         []corev1.Pod
+		wantReason   string
+		wantMessage  string
+		wantRestarts int32
+	}{
+		{
+			name:         "the most frequent reason is dominant",
+			pods:         []corev1.Pod{pod(backingOff), pod(waiting("CrashLoopBackOff", "", 2)), pod(waiting("ImagePullBackOff", "", 0))},
+			wantReason:   "CrashLoopBackOff",
+			wantMessage:  "back-off restarting, last terminated with Error (exit code 1)",
+			wantRestarts: 5,
+		},
+		{
+			name:        "the reasons with the same count are ordered by name",
+			pods:        []corev1.Pod{pod(waiting("ImagePullBackOff", "pull back-off", 0)), pod(waiting("ErrImagePull", "pull error", 0))},
+			wantReason:  "ErrImagePull",
+			wantMessage: "pull error",
+		},
+		{
+			name:        "unschedulable pods",
+			pods:        []corev1.Pod{unschedulable, unschedulable, pod(backingOff)},
+			wantReason:  corev1.PodReasonUnschedulable,
+			wantMessage: "0/3 nodes are available",
+			// the restarts of the backing off container are counted
+			wantRestarts: 3,
+		},
+		{
+			name: "failed containers and init containers",
+			pods: []corev1.Pod{{Status: corev1.PodStatus{
+				InitContainerStatuses: []corev1.ContainerStatus{{State: corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{Reason: "Error", Message: "init failed", ExitCode: 2},
+				}}},
+			}}},
+			wantReason:  "Error",
+			wantMessage: "init failed",
+		},
+		{
+			name: "starting containers are healthy",
+			pods: []corev1.Pod{pod(waiting("ContainerCreating", "", 0)), pod(waiting("PodInitializing", "", 0))},
+		},
+		{
+			name: "succeeded and deleting pods are skipped",
+			pods: []corev1.Pod{succeeded, deleting},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replicas := int32(3)
+			deployment := &appsv1.Deployment{
+				Spec: appsv1.DeploymentSpec{
+					Replicas: &replicas,
+					Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Image: "erda:1.0"}},
+					}},
+				},
+				Status: appsv1.DeploymentStatus{ReadyReplicas: 1},
+			}
+			status := &erdav1beta1.ComponentStatus{}
+			ComposePodsStatus(status, deployment, tt.pods)
+			if status.Replicas != 3 || status.ReadyReplicas != 1 || status.Image != "erda:1.0" {
+				t.Errorf("replicas and image = (%d, %d, %s), want (3, 1, erda:1.0)",
+					status.Replicas, status.ReadyReplicas, status.Image)
+			}
+			if status.ContainerReason != tt.wantReason || status.ContainerMessage != tt.wantMessage {
+				t.Errorf("container reason = (%s, %q), want (%s, %q)", status.ContainerReason,
+					status.ContainerMessage, tt.wantReason, tt.wantMessage)
+			}
+			if status.Restarts != tt.wantRestarts {
+				t.Errorf("restarts = %d, want %d", status.Restarts, tt.wantRestarts)
+			}
+		})
+	}
+}
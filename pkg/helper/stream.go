@@ -0,0 +1,56 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+)
+
+// ComposeIngressNginxStreams returns the ingress-nginx tcp-services and udp-services
+// entries of the component, the key is the external port and the value is
+// the service address in format namespace/name:port
+func ComposeIngressNginxStreams(component *erdav1beta1.Component) map[corev1.Protocol]map[string]string {
+	streams := map[corev1.Protocol]map[string]string{
+		corev1.ProtocolTCP: {},
+		corev1.ProtocolUDP: {},
+	}
+	for _, sd := range component.Network.ServiceDiscovery {
+		if sd.Expose == nil || sd.Expose.Type != erdav1beta1.ExposeIngressNginx {
+			continue
+		}
+		port := sd.Expose.Port
+		if port == 0 {
+			port = sd.Port
+		}
+		streams[GetKubernetesProtocol(sd.Protocol)][fmt.Sprintf("%d", port)] =
+			ComposeIngressNginxStreamTarget(component.Namespace, component.Name, sd.Port)
+	}
+	return streams
+}
+
+// ComposeIngressNginxStreamTarget returns the ingress-nginx stream entry value of the service port
+func ComposeIngressNginxStreamTarget(namespace, name string, port int32) string {
+	return fmt.Sprintf("%s/%s:%d", namespace, name, port)
+}
+
+// IsIngressNginxStreamOf reports whether the stream entry value points to the service
+func IsIngressNginxStreamOf(target, namespace, name string) bool {
+	return strings.HasPrefix(target, fmt.Sprintf("%s/%s:", namespace, name))
+}
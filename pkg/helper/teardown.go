@@ -0,0 +1,139 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+)
+
+// IsPreDeleteJob reports whether the job is run before the Erda is torn down
+func IsPreDeleteJob(job *erdav1beta1.Job) bool {
+	return job.Type == erdav1beta1.PreDeleteJobType
+}
+
+// HasPreJobs reports whether the Erda has the jobs which are run before deploying
+func HasPreJobs(erda *erdav1beta1.Erda) bool {
+	for i := range erda.Spec.Jobs {
+		if !IsPreDeleteJob(&erda.Spec.Jobs[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsVolumeRetained reports whether the claim of the volume is kept after the Erda is deleted
+func IsVolumeRetained(volume erdav1beta1.Volume) bool {
+	return volume.RetainPolicy != erdav1beta1.RetainPolicyDelete
+}
+
+// IsConfigurationRetained reports whether the configuration is kept after the Erda is deleted,
+// the configurations without data are not managed by the operator and always kept
+func IsConfigurationRetained(config erdav1beta1.Configuration) bool {
+	if len(config.Data) == 0 && len(config.StringData) == 0 {
+		return true
+	}
+	return config.RetainPolicy != erdav1beta1.RetainPolicyDelete
+}
+
+// ComposeDeletedClaimNames returns the names of the claims which are deleted with the Erda, the
+// claim is kept if any volume which declares it is retained or any other Erda declares it
+func ComposeDeletedClaimNames(erda *erdav1beta1.Erda, others []erdav1beta1.Erda) []string {
+	names, retained := composeClaimRetention(erda)
+	for i := range others {
+		otherNames, _ := composeClaimRetention(&others[i])
+		for _, name := range otherNames {
+			retained[name] = true
+		}
+	}
+	return filterRetained(names, retained)
+}
+
+func composeClaimRetention(erda *erdav1beta1.Erda) ([]string, map[string]bool) {
+	retained, names := map[string]bool{}, []string{}
+	for _, app := range erda.Spec.Applications {
+		for _, component := range app.Components {
+			for index, volume := range component.Storage.Volumes {
+				if volume.StorageClass == "" {
+					continue
+				}
+				name := fmt.Sprintf("pvc-%s-%d", component.Name, index+1)
+				if _, ok := retained[name]; !ok {
+					names = append(names, name)
+				}
+				retained[name] = retained[name] || IsVolumeRetained(volume)
+			}
+		}
+	}
+	return names, retained
+}
+
+// ComposeDeletedConfigurationNames returns the names of the configurations of the type which are
+// deleted with the Erda, the configuration is kept if any component which declares it retains it
+// or any other Erda declares it
+func ComposeDeletedConfigurationNames(erda *erdav1beta1.Erda, others []erdav1beta1.Erda,
+	configurationType erdav1beta1.ConfigurationType) []string {
+	names, retained := composeConfigurationRetention(erda, configurationType)
+	for i := range others {
+		otherNames, _ := composeConfigurationRetention(&others[i], configurationType)
+		for _, name := range otherNames {
+			retained[name] = true
+		}
+	}
+	return filterRetained(names, retained)
+}
+
+func composeConfigurationRetention(erda *erdav1beta1.Erda,
+	configurationType erdav1beta1.ConfigurationType) ([]string, map[string]bool) {
+	retained, names := map[string]bool{}, []string{}
+	for _, app := range erda.Spec.Applications {
+		for _, component := range app.Components {
+			for _, config := range component.Configurations {
+				if config.Type != configurationType {
+					continue
+				}
+				if _, ok := retained[config.Name]; !ok {
+					names = append(names, config.Name)
+				}
+				retained[config.Name] = retained[config.Name] || IsConfigurationRetained(config)
+			}
+		}
+	}
+	return names, retained
+}
+
+func filterRetained(names []string, retained map[string]bool) []string {
+	deleted := []string{}
+	for _, name := range names {
+		if !retained[name] {
+			deleted = append(deleted, name)
+		}
+	}
+	return deleted
+}
+
+// IsOwnedByErda reports whether the object is owned by the Erda, the objects named after the
+// components of another Erda in the same namespace are not torn down
+func IsOwnedByErda(obj client.Object, erda *erdav1beta1.Erda) bool {
+	for _, reference := range obj.GetOwnerReferences() {
+		if reference.UID == erda.UID {
+			return true
+		}
+	}
+	return false
+}
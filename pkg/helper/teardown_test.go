@@ -0,0 +1,147 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+)
+
+func composeTeardownErda(name string, components ...erdav1beta1.Component) erdav1beta1.Erda {
+	return erdav1beta1.Erda{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "erda"},
+		Spec: &erdav1beta1.ErdaSpec{
+			Applications: []erdav1beta1.Application{{Components: components}},
+		},
+	}
+}
+
+func composeVolumeComponent(name string, volumes ...erdav1beta1.Volume) erdav1beta1.Component {
+	return erdav1beta1.Component{
+		Metadata:      erdav1beta1.Metadata{Name: name},
+		ComponentSpec: erdav1beta1.ComponentSpec{Storage: erdav1beta1.Storage{Volumes: volumes}},
+	}
+}
+
+func composeConfigurationComponent(name string, configs ...erdav1beta1.Configuration) erdav1beta1.Component {
+	return erdav1beta1.Component{
+		Metadata:      erdav1beta1.Metadata{Name: name},
+		ComponentSpec: erdav1beta1.ComponentSpec{Configurations: configs},
+	}
+}
+
+func TestComposeDeletedClaimNames(t *testing.T) {
+	deleted := erdav1beta1.Volume{StorageClass: "ssd", RetainPolicy: erdav1beta1.RetainPolicyDelete}
+	retained := erdav1beta1.Volume{StorageClass: "ssd"}
+	hostPath := erdav1beta1.Volume{SourcePath: "/data", RetainPolicy: erdav1beta1.RetainPolicyDelete}
+
+	tests := []struct {
+		name   string
+		erda   erdav1beta1.Erda
+		others []erdav1beta1.Erda
+		want   []string
+	}{
+		{
+			name: "the claims are retained by default",
+			erda: composeTeardownErda("erda", composeVolumeComponent("mysql", deleted, hostPath, retained),
+				composeVolumeComponent("redis", deleted)),
+			want: []string{"pvc-mysql-1", "pvc-redis-1"},
+		},
+		{
+			name: "the claim is retained by any volume which declares it",
+			erda: composeTeardownErda("erda", composeVolumeComponent("mysql", deleted),
+				composeVolumeComponent("mysql", retained)),
+			want: []string{},
+		},
+		{
+			name:   "the claim declared by another Erda is retained",
+			erda:   composeTeardownErda("erda", composeVolumeComponent("mysql", deleted), composeVolumeComponent("redis", deleted)),
+			others: []erdav1beta1.Erda{composeTeardownErda("other", composeVolumeComponent("redis", deleted))},
+			want:   []string{"pvc-mysql-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ComposeDeletedClaimNames(&tt.erda, tt.others); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ComposeDeletedClaimNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComposeDeletedConfigurationNames(t *testing.T) {
+	composeConfiguration := func(name string, configurationType erdav1beta1.ConfigurationType,
+		policy erdav1beta1.RetainPolicy, withData bool) erdav1beta1.Configuration {
+		config := erdav1beta1.Configuration{Name: name, Type: configurationType, RetainPolicy: policy}
+		if withData {
+			config.StringData = map[string]string{"key": "value"}
+		}
+		return config
+	}
+
+	tests := []struct {
+		name              string
+		erda              erdav1beta1.Erda
+		others            []erdav1beta1.Erda
+		configurationType erdav1beta1.ConfigurationType
+		want              []string
+	}{
+		{
+			name: "the configurations of the type with data are deleted by the policy",
+			erda: composeTeardownErda("erda", composeConfigurationComponent("web",
+				composeConfiguration("web-config", erdav1beta1.ConfigurationConfigMap, erdav1beta1.RetainPolicyDelete, true),
+				composeConfiguration("web-retained", erdav1beta1.ConfigurationConfigMap, "", true),
+				composeConfiguration("web-external", erdav1beta1.ConfigurationConfigMap, erdav1beta1.RetainPolicyDelete, false),
+				composeConfiguration("web-secret", erdav1beta1.ConfigurationSecret, erdav1beta1.RetainPolicyDelete, true),
+			)),
+			configurationType: erdav1beta1.ConfigurationConfigMap,
+			want:              []string{"web-config"},
+		},
+		{
+			name: "the configuration is retained by any component which declares it",
+			erda: composeTeardownErda("erda",
+				composeConfigurationComponent("web",
+					composeConfiguration("shared", erdav1beta1.ConfigurationSecret, erdav1beta1.RetainPolicyDelete, true)),
+				composeConfigurationComponent("api",
+					composeConfiguration("shared", erdav1beta1.ConfigurationSecret, erdav1beta1.RetainPolicyRetain, true)),
+			),
+			configurationType: erdav1beta1.ConfigurationSecret,
+			want:              []string{},
+		},
+		{
+			name: "the configuration declared by another Erda is retained",
+			erda: composeTeardownErda("erda", composeConfigurationComponent("web",
+				composeConfiguration("web-config", erdav1beta1.ConfigurationConfigMap, erdav1beta1.RetainPolicyDelete, true),
+				composeConfiguration("shared", erdav1beta1.ConfigurationConfigMap, erdav1beta1.RetainPolicyDelete, true),
+			)),
+			others: []erdav1beta1.Erda{composeTeardownErda("other", composeConfigurationComponent("web",
+				composeConfiguration("shared", erdav1beta1.ConfigurationConfigMap, "", false)))},
+			configurationType: erdav1beta1.ConfigurationConfigMap,
+			want:              []string{"web-config"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ComposeDeletedConfigurationNames(&tt.erda, tt.others, tt.configurationType)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ComposeDeletedConfigurationNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
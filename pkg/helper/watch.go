@@ -0,0 +1,37 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"k8s.io/apimachinery/pkg/labels"
+
+	erdav1beta1 "github.com/erda-project/erda-operator/api/v1beta1"
+)
+
+// ComposeDeclaredLabels returns the labels of the PersistentVolumeClaims and the configurations
+// which are applied for the Erda, they are not owned by the Erda to outlive it, so the Erda is
+// found by the labels when they change
+func ComposeDeclaredLabels(erdaName string) map[string]string {
+	return map[string]string{
+		erdav1beta1.ErdaOperatorLabel: "true",
+		erdav1beta1.ErdaNameLabel:     erdaName,
+	}
+}
+
+// ComposeWatchedSelector returns the label selector of the ConfigMaps, the Secrets and the
+// PersistentVolumeClaims which are watched, only the ones applied by the operator are cached
+func ComposeWatchedSelector() string {
+	return labels.Set{erdav1beta1.ErdaOperatorLabel: "true"}.String()
+}
@@ -42,15 +42,9 @@ func composeDaemonSetSpecFromErdaComponent(component *erdav1beta1.Component) app
 			}),
 		},
 		Template:             ComposePodTemplateSpecByComponent(component),
-		RevisionHistoryLimit: utils.ConvertInt32ToPointInt32(3),
-	}
-}
-
-func ComposeDaemonSetSpecFromK8sDaemonSet(daemonSet *appsv1.DaemonSet) appsv1.DaemonSetSpec {
-	return appsv1.DaemonSetSpec{
-		Selector:             daemonSet.Spec.Selector,
-		Template:             ComposePodTemplateSpecFromPodTemplate(daemonSet.Spec.Template),
-		RevisionHistoryLimit: daemonSet.Spec.RevisionHistoryLimit,
+		UpdateStrategy:       composeDaemonSetUpdateStrategy(component),
+		MinReadySeconds:      composeRollout(component).MinReadySeconds,
+		RevisionHistoryLimit: composeRevisionHistoryLimit(component),
 	}
 }
 
@@ -71,18 +65,10 @@ func composeStatefulSetSpecFromErdaComponent(component *erdav1beta1.Component) a
 			}),
 		},
 		Template:             ComposePodTemplateSpecByComponent(component),
-		ServiceName:          component.Name,
-		RevisionHistoryLimit: utils.ConvertInt32ToPointInt32(3),
-	}
-}
-
-func ComposeStatefulSetSpecFromK8sStatefulSet(statefulSet *appsv1.StatefulSet) appsv1.StatefulSetSpec {
-	return appsv1.StatefulSetSpec{
-		Replicas:             statefulSet.Spec.Replicas,
-		Selector:             statefulSet.Spec.Selector,
-		Template:             ComposePodTemplateSpecFromPodTemplate(statefulSet.Spec.Template),
-		ServiceName:          statefulSet.Spec.ServiceName,
-		RevisionHistoryLimit: statefulSet.Spec.RevisionHistoryLimit,
+		ServiceName:          ComposeHeadlessServiceName(component.Name),
+		PodManagementPolicy:  composePodManagementPolicy(component),
+		UpdateStrategy:       composeStatefulSetUpdateStrategy(component),
+		RevisionHistoryLimit: composeRevisionHistoryLimit(component),
 	}
 }
 
@@ -102,16 +88,10 @@ func composeDeploymentSpecFromErdaComponent(component *erdav1beta1.Component) ap
 				erdav1beta1.ErdaComponentLabel: component.Name,
 			}),
 		},
-		Template:             ComposePodTemplateSpecByComponent(component),
-		RevisionHistoryLimit: utils.ConvertInt32ToPointInt32(3),
-	}
-}
-
-func ComposeDeploymentSpecFromK8sDeployment(deployment *appsv1.Deployment) appsv1.DeploymentSpec {
-	return appsv1.DeploymentSpec{
-		Replicas:             deployment.Spec.Replicas,
-		Selector:             deployment.Spec.Selector,
-		Template:             ComposePodTemplateSpecFromPodTemplate(deployment.Spec.Template),
-		RevisionHistoryLimit: deployment.Spec.RevisionHistoryLimit,
+		Template:                ComposePodTemplateSpecByComponent(component),
+		Strategy:                composeDeploymentStrategy(component),
+		MinReadySeconds:         composeRollout(component).MinReadySeconds,
+		ProgressDeadlineSeconds: composeProgressDeadlineSeconds(component),
+		RevisionHistoryLimit:    composeRevisionHistoryLimit(component),
 	}
 }
@@ -155,7 +155,33 @@ func ComposeResourceToEnvs(component erdav1beta1.Component) []corev1.EnvVar {
 	}
 }
 
-func ComposeDependEnvs(erda erdav1beta1.Erda) []corev1.EnvVar {
+// ComposeServiceAddress returns the in-cluster address of the service port by the address format,
+// the fqdn format is default
+func ComposeServiceAddress(name, namespace string, port int32, format erdav1beta1.ServiceAddressFormat,
+	clusterDomain string) string {
+	switch format {
+	case erdav1beta1.ServiceAddressShort:
+		return fmt.Sprintf("%s:%d", name, port)
+	case erdav1beta1.ServiceAddressNamespaced:
+		return fmt.Sprintf("%s.%s:%d", name, namespace, port)
+	default:
+		if clusterDomain == "" {
+			clusterDomain = DefaultClusterDomain
+		}
+		return fmt.Sprintf("%s.%s.svc.%s:%d", name, namespace, clusterDomain, port)
+	}
+}
+
+// ComposeServiceAddressFormat returns the address format of the component services, the annotation
+// of the component overwrites the one of the application
+func ComposeServiceAddressFormat(appAnnotations, componentAnnotations map[string]string) erdav1beta1.ServiceAddressFormat {
+	if format, ok := componentAnnotations[erdav1beta1.AnnotationServiceAddressFormat]; ok {
+		return erdav1beta1.ServiceAddressFormat(format)
+	}
+	return erdav1beta1.ServiceAddressFormat(appAnnotations[erdav1beta1.AnnotationServiceAddressFormat])
+}
+
+func ComposeDependEnvs(erda erdav1beta1.Erda, clusterDomain string) []corev1.EnvVar {
 	envs := make([]corev1.EnvVar, 0)
 	for _, app := range erda.Spec.Applications {
 		for _, component := range app.Components {
@@ -166,11 +192,13 @@ func ComposeDependEnvs(erda erdav1beta1.Erda) []corev1.EnvVar {
 			if len(component.Network.ServiceDiscovery) > 0 {
 				sd := component.Network.ServiceDiscovery[0]
 				convertedCompName := strings.ToUpper(strings.ReplaceAll(component.Name, "-", "_"))
+				format := ComposeServiceAddressFormat(app.Annotations, component.Annotations)
 				envs = append(envs, corev1.EnvVar{
-					Name: fmt.Sprintf("%s_ADDR", convertedCompName),
-					// TODO: Kubernetes service name, svc.cluster.local is default
-					Value: fmt.Sprintf("%s.%s.svc.cluster.local:%d", component.Name, erda.Namespace, sd.Port),
+					Name:  fmt.Sprintf("%s_ADDR", convertedCompName),
+					Value: ComposeServiceAddress(component.Name, erda.Namespace, sd.Port, format, clusterDomain),
 				})
+				envs = append(envs, ComposePortsADDREnvs(convertedCompName, component.Name, erda.Namespace,
+					component.Network.ServiceDiscovery, format, clusterDomain)...)
 				if sd.Domain == "" {
 					continue
 				}
@@ -190,13 +218,16 @@ func ComposeDependEnvs(erda erdav1beta1.Erda) []corev1.EnvVar {
 	return envs
 }
 
-func ComposeSelfADDREnv(component erdav1beta1.Component, protocol string) []corev1.EnvVar {
+func ComposeSelfADDREnv(component erdav1beta1.Component, format erdav1beta1.ServiceAddressFormat,
+	protocol, clusterDomain string) []corev1.EnvVar {
 	envs := make([]corev1.EnvVar, 0)
 	envs = append(envs, corev1.EnvVar{
 		Name: "SELF_ADDR",
-		// TODO: kubernetes services
-		Value: fmt.Sprintf("%s.%s.svc.cluster.local:%d", component.Name, component.Namespace, component.Network.ServiceDiscovery[0].Port),
+		Value: ComposeServiceAddress(component.Name, component.Namespace, component.Network.ServiceDiscovery[0].Port,
+			format, clusterDomain),
 	})
+	envs = append(envs, ComposePortsADDREnvs("SELF", component.Name, component.Namespace,
+		component.Network.ServiceDiscovery, format, clusterDomain)...)
 	if component.Network.ServiceDiscovery[0].Domain != "" {
 		envs = append(envs, []corev1.EnvVar{
 			{
@@ -212,6 +243,77 @@ func ComposeSelfADDREnv(component erdav1beta1.Component, protocol string) []core
 	return envs
 }
 
+// ComposePortsADDREnvs returns the addresses of all service discovery ports, e.g.
+// <PREFIX>_ADDR_<PORT> for every port, <PREFIX>_<PROTOCOL>_ADDR and <PREFIX>_<PROTOCOL>_URL
+// for the first port of every protocol, the url is only for the application protocols
+func ComposePortsADDREnvs(prefix, name, namespace string, sds []erdav1beta1.ServiceDiscovery,
+	format erdav1beta1.ServiceAddressFormat, clusterDomain string) []corev1.EnvVar {
+	envs := make([]corev1.EnvVar, 0)
+	portVisited := make(map[int32]bool)
+	protocolVisited := make(map[string]bool)
+	for _, sd := range sds {
+		addr := ComposeServiceAddress(name, namespace, sd.Port, format, clusterDomain)
+		if !portVisited[sd.Port] {
+			portVisited[sd.Port] = true
+			envs = append(envs, corev1.EnvVar{
+				Name:  fmt.Sprintf("%s_ADDR_%d", prefix, sd.Port),
+				Value: addr,
+			})
+		}
+
+		protocol := strings.ToUpper(sd.Protocol)
+		if protocol == "" {
+			protocol = "TCP"
+		}
+		if protocolVisited[protocol] {
+			continue
+		}
+		protocolVisited[protocol] = true
+		envs = append(envs, corev1.EnvVar{
+			Name:  fmt.Sprintf("%s_%s_ADDR", prefix, protocol),
+			Value: addr,
+		})
+		switch protocol {
+		case "HTTP", "HTTPS", "GRPC":
+			envs = append(envs, corev1.EnvVar{
+				Name:  fmt.Sprintf("%s_%s_URL", prefix, protocol),
+				Value: fmt.Sprintf("%s://%s", strings.ToLower(protocol), addr),
+			})
+		}
+	}
+	return envs
+}
+
+// ComposeOrdinalADDREnvs returns the per-ordinal addresses of the Stateful component
+// as SELF_ORDINAL_ADDR_<ordinal>, and all of them joined by comma as SELF_ORDINAL_ADDRS
+func ComposeOrdinalADDREnvs(component erdav1beta1.Component, format erdav1beta1.ServiceAddressFormat,
+	clusterDomain string) []corev1.EnvVar {
+	if component.WorkLoad != erdav1beta1.Stateful || !component.Network.OrdinalServices ||
+		len(component.Network.ServiceDiscovery) == 0 {
+		return nil
+	}
+	replicas := int32(1)
+	if component.Replicas != nil {
+		replicas = *component.Replicas
+	}
+	envs := make([]corev1.EnvVar, 0, replicas+1)
+	addrs := make([]string, 0, replicas)
+	for ordinal := int32(0); ordinal < replicas; ordinal++ {
+		addr := ComposeServiceAddress(fmt.Sprintf("%s-%d", component.Name, ordinal), component.Namespace,
+			component.Network.ServiceDiscovery[0].Port, format, clusterDomain)
+		addrs = append(addrs, addr)
+		envs = append(envs, corev1.EnvVar{
+			Name:  fmt.Sprintf("SELF_ORDINAL_ADDR_%d", ordinal),
+			Value: addr,
+		})
+	}
+	envs = append(envs, corev1.EnvVar{
+		Name:  "SELF_ORDINAL_ADDRS",
+		Value: strings.Join(addrs, ","),
+	})
+	return envs
+}
+
 func ReplaceDependsEnv(dependEnvs []corev1.EnvVar, envs []corev1.EnvVar) []corev1.EnvVar {
 	for _, denv := range dependEnvs {
 		isExisted := false
@@ -0,0 +1,76 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/discovery"
+)
+
+// DetectServedResourceVersion returns the first group version in candidates
+// which serves the given resource
+func DetectServedResourceVersion(client discovery.DiscoveryInterface, resource string,
+	candidates ...string) (string, error) {
+	for _, groupVersion := range candidates {
+		resources, err := client.ServerResourcesForGroupVersion(groupVersion)
+		if err != nil {
+			if k8sErrors.IsNotFound(err) {
+				continue
+			}
+			return "", err
+		}
+		for _, r := range resources.APIResources {
+			if r.Name == resource {
+				return groupVersion, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("resource %s is not served in %v", resource, candidates)
+}
+
+const (
+	DefaultClusterDomain = "cluster.local"
+	resolvConfPath       = "/etc/resolv.conf"
+)
+
+// DetectClusterDomain detects the cluster DNS domain from the search domains
+// of resolv.conf in pod, e.g. the domain of "default.svc.cluster.local" is "cluster.local".
+// The default cluster domain returns if it can not be detected.
+func DetectClusterDomain() string {
+	f, err := os.Open(resolvConfPath)
+	if err != nil {
+		return DefaultClusterDomain
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] != "search" {
+			continue
+		}
+		for _, search := range fields[1:] {
+			if strings.HasPrefix(search, "svc.") {
+				return strings.TrimSuffix(strings.TrimPrefix(search, "svc."), ".")
+			}
+		}
+	}
+	return DefaultClusterDomain
+}